@@ -0,0 +1,70 @@
+package builtins
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+	"github.com/tsukinoko-kun/ohmygosh/internal/shell"
+)
+
+func alias(ctx *Context, args []string) int {
+	if len(args) == 0 {
+		names := make([]string, 0, len(config.Get.Shell.Alias))
+		for name := range config.Get.Shell.Alias {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Fprintf(ctx.Stdout, "alias %s=%s\n", name, shell.Quote(config.Get.Shell.Alias[name]))
+		}
+		return 0
+	}
+
+	exitCode := 0
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !hasValue {
+			if value, ok := config.Get.Shell.Alias[name]; ok {
+				fmt.Fprintf(ctx.Stdout, "alias %s=%s\n", name, shell.Quote(value))
+			} else {
+				fmt.Fprintf(ctx.Stderr, "alias: %s: not found\n", name)
+				exitCode = 1
+			}
+			continue
+		}
+		if name == "" || strings.ContainsAny(name, " \t/$`'\"=") {
+			fmt.Fprintf(ctx.Stderr, "alias: `%s': invalid alias name\n", name)
+			exitCode = 1
+			continue
+		}
+		if config.Get.Shell.Alias == nil {
+			config.Get.Shell.Alias = make(map[string]string)
+		}
+		config.Get.Shell.Alias[name] = value
+	}
+	return exitCode
+}
+
+func unalias(ctx *Context, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(ctx.Stderr, "unalias: usage: unalias [-a] name [name ...]")
+		return 2
+	}
+	if args[0] == "-a" {
+		clear(config.Get.Shell.Alias)
+		return 0
+	}
+
+	exitCode := 0
+	for _, name := range args {
+		if _, ok := config.Get.Shell.Alias[name]; !ok {
+			fmt.Fprintf(ctx.Stderr, "unalias: %s: not found\n", name)
+			exitCode = 1
+			continue
+		}
+		delete(config.Get.Shell.Alias, name)
+	}
+	return exitCode
+}
@@ -0,0 +1,59 @@
+package builtins
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// asciicast exports the recording of blocks as an asciicast file:
+// `asciicast FILE [ID|FIRST-LAST]`. Without ids it exports the last block.
+func asciicast(ctx *Context, args []string) int {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Fprintln(ctx.Stderr, "usage: asciicast FILE [ID|FIRST-LAST]")
+		return 2
+	}
+
+	first, last := 0, 0
+	if len(args) == 2 {
+		var ok bool
+		if first, last, ok = blockRange(args[1]); !ok {
+			fmt.Fprintf(ctx.Stderr, "asciicast: %s: invalid block range\n", args[1])
+			return 1
+		}
+	}
+
+	f, err := os.Create(args[0])
+	if err != nil {
+		fmt.Fprintf(ctx.Stderr, "asciicast: %v\n", err)
+		return 1
+	}
+	err = ctx.Cast(f, first, last)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(args[0])
+		fmt.Fprintf(ctx.Stderr, "asciicast: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// blockRange parses `ID` or `FIRST-LAST`.
+func blockRange(s string) (first, last int, ok bool) {
+	from, to, isRange := strings.Cut(s, "-")
+	first, err := strconv.Atoi(from)
+	if err != nil || first < 1 {
+		return 0, 0, false
+	}
+	if !isRange {
+		return first, first, true
+	}
+	last, err = strconv.Atoi(to)
+	if err != nil || last < first {
+		return 0, 0, false
+	}
+	return first, last, true
+}
@@ -0,0 +1,59 @@
+package builtins_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/builtins"
+)
+
+func TestAsciicast(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   string
+		output string
+		code   int
+	}{
+		{name: "last block", args: "", output: "0-0", code: 0},
+		{name: "one block", args: " 3", output: "3-3", code: 0},
+		{name: "range", args: " 2-5", output: "2-5", code: 0},
+		{name: "reversed range", args: " 5-2", code: 1},
+		{name: "not a number", args: " x", code: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.cast")
+			b, args, ok := builtins.Lookup("asciicast " + path + tt.args)
+			if !ok {
+				t.Fatal("asciicast is not a builtin")
+			}
+			var stderr strings.Builder
+			ctx := &builtins.Context{
+				Stdout: io.Discard,
+				Stderr: &stderr,
+				Cast: func(w io.Writer, first, last int) error {
+					_, err := fmt.Fprintf(w, "%d-%d", first, last)
+					return err
+				},
+			}
+			if code := b(ctx, args); code != tt.code {
+				t.Fatalf("Expected exit code %d, got %d: %s", tt.code, code, stderr.String())
+			}
+			if tt.code != 0 {
+				return
+			}
+			output, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(output) != tt.output {
+				t.Errorf("Expected %q, got %q", tt.output, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Package builtins implements commands that ohmygosh executes itself instead
+// of sending them to the shell. They finish instantly and don't need a PTY.
+package builtins
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/export"
+	"github.com/tsukinoko-kun/ohmygosh/internal/shell"
+)
+
+// Job is a block that is still running.
+type Job struct {
+	ID        int
+	Pid       int
+	Command   string
+	StartTime time.Time
+	// Stopped is true while the job is suspended.
+	Stopped bool
+}
+
+// Context connects a builtin to the block it runs in and to the UI.
+type Context struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	// Jobs returns the running blocks, oldest first.
+	Jobs func() []Job
+	// Foreground resumes the block with the given id and gives it keyboard
+	// focus.
+	Foreground func(id int) error
+	// Background resumes the block with the given id without giving it focus.
+	Background func(id int) error
+	// Cast writes the recordings of the blocks with ids from first to last
+	// as an asciicast to w. A first of 0 selects the last recorded block.
+	Cast func(w io.Writer, first, last int) error
+	// Export renders the blocks with ids from first to last in format to w.
+	// A first of 0 selects the last block.
+	Export func(w io.Writer, format export.Format, first, last int) error
+	// Clipboard puts text on the clipboard.
+	Clipboard func(text string) error
+}
+
+// Builtin is a command implemented in Go. It returns the exit code.
+type Builtin func(ctx *Context, args []string) int
+
+var registry map[string]Builtin
+
+func init() {
+	registry = map[string]Builtin{
+		"cd":        cd,
+		"pushd":     pushd,
+		"popd":      popd,
+		"dirs":      dirs,
+		"export":    exportBuiltin,
+		"unset":     unset,
+		"alias":     alias,
+		"unalias":   unalias,
+		"history":   historyBuiltin,
+		"jobs":      jobs,
+		"fg":        fg,
+		"bg":        bg,
+		"asciicast": asciicast,
+		"save":      save,
+	}
+}
+
+// Lookup returns the builtin and its arguments if line is a simple command
+// that calls a builtin. Anything the shell has to interpret (pipes, lists,
+// redirections, globs, command substitution) is left to the shell.
+// Parameters are expanded by the shell too: cd, export and unset are left to
+// the session shell, which knows its own variables, while it is alive. The
+// arguments of other builtins are expanded by a new shell.
+func Lookup(line string) (Builtin, []string, bool) {
+	words, expand, ok := Parse(line)
+	if !ok || len(words) == 0 {
+		return nil, nil, false
+	}
+	b, ok := registry[words[0]]
+	if !ok || needsShell(words) {
+		return nil, nil, false
+	}
+	if expand {
+		if slices.Contains(sessionBuiltins, words[0]) && shell.SessionAlive() {
+			return nil, nil, false
+		}
+		expanded, err := shell.Expand(line)
+		if err != nil || len(expanded) == 0 || expanded[0] != words[0] {
+			// let the shell report the error
+			return nil, nil, false
+		}
+		words = expanded
+	}
+	return b, words[1:], true
+}
+
+// sessionBuiltins change the state of the shell, the session shell runs them
+// itself if they refer to its variables.
+var sessionBuiltins = []string{"cd", "export", "unset"}
+
+// needsShell reports whether words use a feature of a builtin that only the
+// shell has: `export NAME` exports a shell variable and `unset -f` removes a
+// function.
+func needsShell(words []string) bool {
+	switch words[0] {
+	case "export":
+		for _, arg := range skipOptions(words[1:], "-p") {
+			if !strings.Contains(arg, "=") {
+				return true
+			}
+		}
+	case "unset":
+		for _, arg := range words[1:] {
+			if strings.HasPrefix(arg, "-") && arg != "-v" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Names returns the names of all builtins in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,174 @@
+package builtins
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+	"github.com/tsukinoko-kun/ohmygosh/internal/shell"
+)
+
+// dirStack is the directory stack of pushd and popd, top first.
+// The current directory is not part of it.
+var dirStack []string
+
+func cd(ctx *Context, args []string) int {
+	args = skipOptions(args, "-L", "-P")
+	if len(args) > 1 {
+		fmt.Fprintln(ctx.Stderr, "cd: too many arguments")
+		return 1
+	}
+
+	var (
+		dir   string
+		print bool
+	)
+	switch {
+	case len(args) == 0:
+		home, ok := config.LookupEnviron("HOME")
+		if !ok {
+			fmt.Fprintln(ctx.Stderr, "cd: HOME not set")
+			return 1
+		}
+		dir = home
+	case args[0] == "-":
+		if shell.OldWd == "" {
+			fmt.Fprintln(ctx.Stderr, "cd: OLDPWD not set")
+			return 1
+		}
+		dir = shell.OldWd
+		print = true
+	default:
+		dir, print = resolveDir(args[0])
+	}
+
+	if err := changeDir(dir); err != nil {
+		fmt.Fprintf(ctx.Stderr, "cd: %v\n", err)
+		return 1
+	}
+	if print {
+		fmt.Fprintln(ctx.Stdout, shell.Wd)
+	}
+	return 0
+}
+
+func pushd(ctx *Context, args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(ctx.Stderr, "pushd: too many arguments")
+		return 1
+	}
+
+	prev := shell.Wd
+	if len(args) == 0 {
+		if len(dirStack) == 0 {
+			fmt.Fprintln(ctx.Stderr, "pushd: no other directory")
+			return 1
+		}
+		if err := changeDir(dirStack[0]); err != nil {
+			fmt.Fprintf(ctx.Stderr, "pushd: %v\n", err)
+			return 1
+		}
+		dirStack[0] = prev
+	} else {
+		dir, _ := resolveDir(args[0])
+		if err := changeDir(dir); err != nil {
+			fmt.Fprintf(ctx.Stderr, "pushd: %v\n", err)
+			return 1
+		}
+		dirStack = append([]string{prev}, dirStack...)
+	}
+
+	return dirs(ctx, nil)
+}
+
+func popd(ctx *Context, args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(ctx.Stderr, "popd: too many arguments")
+		return 1
+	}
+	if len(dirStack) == 0 {
+		fmt.Fprintln(ctx.Stderr, "popd: directory stack empty")
+		return 1
+	}
+	if err := changeDir(dirStack[0]); err != nil {
+		fmt.Fprintf(ctx.Stderr, "popd: %v\n", err)
+		return 1
+	}
+	dirStack = dirStack[1:]
+
+	return dirs(ctx, nil)
+}
+
+func dirs(ctx *Context, args []string) int {
+	if len(args) == 1 && args[0] == "-c" {
+		dirStack = nil
+		return 0
+	}
+	home, _ := config.LookupEnviron("HOME")
+	entries := append([]string{shell.Wd}, dirStack...)
+	for i, dir := range entries {
+		if home != "" && (dir == home || strings.HasPrefix(dir, home+string(filepath.Separator))) {
+			entries[i] = "~" + dir[len(home):]
+		}
+	}
+	fmt.Fprintln(ctx.Stdout, strings.Join(entries, " "))
+	return 0
+}
+
+// resolveDir makes dir absolute. Relative names are looked up in CDPATH first,
+// found reports whether a CDPATH entry was used.
+func resolveDir(dir string) (path string, found bool) {
+	if filepath.IsAbs(dir) {
+		return filepath.Clean(dir), false
+	}
+	explicit := dir == "." || dir == ".." ||
+		strings.HasPrefix(dir, "./") || strings.HasPrefix(dir, "../")
+	if cdpath, ok := config.LookupEnviron("CDPATH"); ok && !explicit {
+		for _, entry := range filepath.SplitList(cdpath) {
+			if entry == "" {
+				continue
+			}
+			candidate := filepath.Join(entry, dir)
+			if !filepath.IsAbs(candidate) {
+				candidate = filepath.Join(shell.Wd, candidate)
+			}
+			if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+				return candidate, true
+			}
+		}
+	}
+	return filepath.Join(shell.Wd, dir), false
+}
+
+func changeDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s: not a directory", dir)
+	}
+	return shell.Chdir(dir)
+}
+
+func skipOptions(args []string, options ...string) []string {
+	for len(args) > 0 {
+		known := false
+		for _, option := range options {
+			if args[0] == option {
+				known = true
+				break
+			}
+		}
+		if args[0] == "--" {
+			return args[1:]
+		}
+		if !known {
+			break
+		}
+		args = args[1:]
+	}
+	return args
+}
@@ -0,0 +1,56 @@
+package builtins
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+	"github.com/tsukinoko-kun/ohmygosh/internal/shell"
+)
+
+func exportBuiltin(ctx *Context, args []string) int {
+	args = skipOptions(args, "-p")
+	if len(args) == 0 {
+		env := config.CopyEnviron()
+		slices.Sort(env)
+		for _, e := range env {
+			key, value, _ := strings.Cut(e, "=")
+			fmt.Fprintf(ctx.Stdout, "export %s=%s\n", key, shell.Quote(value))
+		}
+		return 0
+	}
+
+	exitCode := 0
+	for _, arg := range args {
+		key, value, hasValue := strings.Cut(arg, "=")
+		if !shell.IsName(key) {
+			fmt.Fprintf(ctx.Stderr, "export: `%s': not a valid identifier\n", arg)
+			exitCode = 1
+			continue
+		}
+		if hasValue {
+			config.SetEnvironLiteral(key, value)
+		}
+	}
+	return exitCode
+}
+
+func unset(ctx *Context, args []string) int {
+	args = skipOptions(args, "-v")
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(ctx.Stderr, "unset: %s: only variables can be unset\n", args[0])
+		return 2
+	}
+
+	exitCode := 0
+	for _, key := range args {
+		if !shell.IsName(key) {
+			fmt.Fprintf(ctx.Stderr, "unset: `%s': not a valid identifier\n", key)
+			exitCode = 1
+			continue
+		}
+		config.UnsetEnviron(key)
+	}
+	return exitCode
+}
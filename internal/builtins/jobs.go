@@ -0,0 +1,90 @@
+package builtins
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/history"
+)
+
+func historyBuiltin(ctx *Context, args []string) int {
+	lines := history.All()
+	switch {
+	case len(args) == 0:
+	case args[0] == "-c":
+		history.Clear()
+		return 0
+	default:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			fmt.Fprintf(ctx.Stderr, "history: %s: numeric argument required\n", args[0])
+			return 1
+		}
+		if n < len(lines) {
+			lines = lines[len(lines)-n:]
+		}
+	}
+
+	offset := len(history.All()) - len(lines)
+	for i, line := range lines {
+		fmt.Fprintf(ctx.Stdout, "%5d  %s\n", offset+i+1, line)
+	}
+	return 0
+}
+
+func jobs(ctx *Context, args []string) int {
+	for _, job := range ctx.Jobs() {
+		state := "Running"
+		if job.Stopped {
+			state = "Stopped"
+		}
+		runtime := time.Since(job.StartTime).Round(time.Second)
+		fmt.Fprintf(ctx.Stdout, "[%d] %-8s %7d  %8s  %s\n", job.ID, state, job.Pid, runtime, job.Command)
+	}
+	return 0
+}
+
+func fg(ctx *Context, args []string) int {
+	id, ok := jobID(ctx, "fg", args)
+	if !ok {
+		return 1
+	}
+	if err := ctx.Foreground(id); err != nil {
+		fmt.Fprintf(ctx.Stderr, "fg: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func bg(ctx *Context, args []string) int {
+	id, ok := jobID(ctx, "bg", args)
+	if !ok {
+		return 1
+	}
+	if err := ctx.Background(id); err != nil {
+		fmt.Fprintf(ctx.Stderr, "bg: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// jobID parses the job argument (`N` or `%N`). Without one it returns the most
+// recent job.
+func jobID(ctx *Context, name string, args []string) (int, bool) {
+	if len(args) == 0 {
+		running := ctx.Jobs()
+		if len(running) == 0 {
+			fmt.Fprintf(ctx.Stderr, "%s: current: no such job\n", name)
+			return 0, false
+		}
+		return running[len(running)-1].ID, true
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "%"))
+	if err != nil {
+		fmt.Fprintf(ctx.Stderr, "%s: %s: no such job\n", name, args[0])
+		return 0, false
+	}
+	return id, true
+}
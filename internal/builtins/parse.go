@@ -0,0 +1,143 @@
+package builtins
+
+import (
+	"strings"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+	"github.com/tsukinoko-kun/ohmygosh/internal/shell"
+)
+
+// Parse splits line into words like a POSIX shell would for a simple command.
+// Quotes, backslash escapes and `~` are handled; ok is false if the line uses
+// any syntax that only the shell can interpret. Parameters like `$NAME` are
+// left in the words as they are and expand is set, the line has to be split
+// by the shell then, which knows its variables.
+func Parse(line string) (words []string, expand bool, ok bool) {
+	var (
+		word    strings.Builder
+		inWord  bool
+		runes   = []rune(line)
+		endWord = func() {
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		}
+	)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch r {
+		case ' ', '\t':
+			endWord()
+		case '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, false, false
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			inWord = true
+			i = end
+		case '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				switch runes[i] {
+				case '\\':
+					if i+1 < len(runes) && strings.ContainsRune("$`\"\\", runes[i+1]) {
+						i++
+					}
+					word.WriteRune(runes[i])
+				case '$':
+					n := parameterLen(runes[i:])
+					if n == 0 {
+						return nil, false, false
+					}
+					expand = expand || n > 1
+					word.WriteString(string(runes[i : i+n]))
+					i += n - 1
+				case '`':
+					return nil, false, false
+				default:
+					word.WriteRune(runes[i])
+				}
+			}
+			if i >= len(runes) {
+				return nil, false, false
+			}
+			inWord = true
+		case '\\':
+			if i+1 >= len(runes) || runes[i+1] == '\n' {
+				return nil, false, false
+			}
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case '$':
+			n := parameterLen(runes[i:])
+			if n == 0 {
+				return nil, false, false
+			}
+			expand = expand || n > 1
+			word.WriteString(string(runes[i : i+n]))
+			inWord = true
+			i += n - 1
+		case '~':
+			if !inWord && (i+1 >= len(runes) || runes[i+1] == '/' || runes[i+1] == ' ' || runes[i+1] == '\t') {
+				home, _ := config.LookupEnviron("HOME")
+				word.WriteString(home)
+			} else {
+				word.WriteRune(r)
+			}
+			inWord = true
+		case '#':
+			if !inWord {
+				return nil, false, false
+			}
+			word.WriteRune(r)
+		default:
+			if strings.ContainsRune("|&;<>()`*?[]{}\n", r) {
+				return nil, false, false
+			}
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	endWord()
+
+	return words, expand, true
+}
+
+// parameterLen returns the length of the parameter at the start of runes
+// (which starts with `$`), only plain `$NAME`, `${NAME}` and a lone `$` are
+// supported. Special parameters like `$?` and command substitutions are left
+// to the shell, the length is 0 for them.
+func parameterLen(runes []rune) int {
+	if len(runes) < 2 || strings.ContainsRune(" \t\"", runes[1]) {
+		return 1
+	}
+	if runes[1] == '{' {
+		end := indexRune(runes, 2, '}')
+		if end < 0 || !shell.IsName(string(runes[2:end])) {
+			return 0
+		}
+		return end + 1
+	}
+	n := 1
+	for n < len(runes) && shell.IsName(string(runes[1:n+1])) {
+		n++
+	}
+	if n == 1 {
+		return 0
+	}
+	return n
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,135 @@
+package builtins_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/builtins"
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+		expand   bool
+		ok       bool
+	}{
+		{
+			name:     "simple words",
+			input:    "cd  foo\tbar",
+			expected: []string{"cd", "foo", "bar"},
+			ok:       true,
+		},
+		{
+			name:     "quotes",
+			input:    `export A='x y' B="it's" C=a\ b ""`,
+			expected: []string{"export", "A=x y", "B=it's", "C=a b", ""},
+			ok:       true,
+		},
+		{
+			name:     "variables",
+			input:    `cd $OHMYGOSH_TEST "${OHMYGOSH_TEST}/x"`,
+			expected: []string{"cd", "$OHMYGOSH_TEST", "${OHMYGOSH_TEST}/x"},
+			expand:   true,
+			ok:       true,
+		},
+		{
+			name:  "special parameter",
+			input: "cd $?",
+			ok:    false,
+		},
+		{
+			name:  "pipe",
+			input: "cd foo | cat",
+			ok:    false,
+		},
+		{
+			name:  "list",
+			input: "cd foo && make",
+			ok:    false,
+		},
+		{
+			name:  "command substitution",
+			input: "cd $(git rev-parse --show-toplevel)",
+			ok:    false,
+		},
+		{
+			name:  "glob",
+			input: "cd src*",
+			ok:    false,
+		},
+		{
+			name:  "unterminated quote",
+			input: "cd 'foo",
+			ok:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words, expand, ok := builtins.Parse(tt.input)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v", tt.ok, ok)
+			}
+			if expand != tt.expand {
+				t.Errorf("Expected expand=%v, got %v", tt.expand, expand)
+			}
+			if ok && !slices.Equal(words, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, words)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	config.SetEnvironLiteral("OHMYGOSH_TEST", "a b")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+		ok       bool
+	}{
+		{
+			name:     "field splitting",
+			input:    `pushd $OHMYGOSH_TEST "${OHMYGOSH_TEST}/x"`,
+			expected: []string{"a", "b", "a b/x"},
+			ok:       true,
+		},
+		{
+			name:     "export with value",
+			input:    "export A=1",
+			expected: []string{"A=1"},
+			ok:       true,
+		},
+		{
+			name:  "export of a shell variable",
+			input: "export A",
+			ok:    false,
+		},
+		{
+			name:  "unset function",
+			input: "unset -f f",
+			ok:    false,
+		},
+		{
+			name:  "not a builtin",
+			input: "make $OHMYGOSH_TEST",
+			ok:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, args, ok := builtins.Lookup(tt.input)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v", tt.ok, ok)
+			}
+			if ok && !slices.Equal(args, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package builtins
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/export"
+)
+
+const saveUsage = "usage: save [-f html|markdown|text|output] FILE [ID|FIRST-LAST]\n       save [-f html|markdown|text|output] -c [ID|FIRST-LAST]"
+
+// save exports blocks as HTML, Markdown or text to a file or the clipboard.
+// Without -f the format of a file is taken from its extension, the clipboard
+// gets text. Without ids it exports the last block.
+func save(ctx *Context, args []string) int {
+	var format export.Format
+	clipboard := false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		switch args[0] {
+		case "-c":
+			clipboard = true
+			args = args[1:]
+		case "-f":
+			if len(args) < 2 {
+				fmt.Fprintln(ctx.Stderr, saveUsage)
+				return 2
+			}
+			f, err := export.ParseFormat(args[1])
+			if err != nil {
+				fmt.Fprintf(ctx.Stderr, "save: %v\n", err)
+				return 2
+			}
+			format = f
+			args = args[2:]
+		default:
+			fmt.Fprintf(ctx.Stderr, "save: %s: invalid option\n%s\n", args[0], saveUsage)
+			return 2
+		}
+	}
+
+	var path string
+	if !clipboard {
+		if len(args) == 0 {
+			fmt.Fprintln(ctx.Stderr, saveUsage)
+			return 2
+		}
+		path, args = args[0], args[1:]
+	}
+	if len(args) > 1 {
+		fmt.Fprintln(ctx.Stderr, saveUsage)
+		return 2
+	}
+	first, last := 0, 0
+	if len(args) == 1 {
+		var ok bool
+		if first, last, ok = blockRange(args[0]); !ok {
+			fmt.Fprintf(ctx.Stderr, "save: %s: invalid block range\n", args[0])
+			return 1
+		}
+	}
+	if format == "" {
+		format = export.Text
+		if path != "" {
+			format = export.FormatOf(path)
+		}
+	}
+
+	if clipboard {
+		var out strings.Builder
+		err := ctx.Export(&out, format, first, last)
+		if err == nil {
+			err = ctx.Clipboard(out.String())
+		}
+		if err != nil {
+			fmt.Fprintf(ctx.Stderr, "save: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(ctx.Stderr, "save: %v\n", err)
+		return 1
+	}
+	err = ctx.Export(f, format, first, last)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		fmt.Fprintf(ctx.Stderr, "save: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,185 @@
+// Package cast reads and writes recordings of terminal output in the
+// asciicast v2 format of asciinema.
+//
+// A recording is a header line followed by one event per line, both JSON:
+//
+//	{"version": 2, "width": 80, "height": 24}
+//	[0.248848, "o", "hello\r\n"]
+//	[1.001376, "r", "100x40"]
+package cast
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// Header is the first line of a recording.
+type Header struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+	Command   string `json:"command,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+// Event types
+const (
+	Output = "o"
+	Input  = "i"
+	Resize = "r"
+)
+
+// Event is a line after the header. Time is in seconds since the start of the
+// recording.
+type Event struct {
+	Time float64
+	Type string
+	Data string
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{math.Round(e.Time*1e6) / 1e6, e.Type, e.Data})
+}
+
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("event has %d fields instead of 3", len(fields))
+	}
+	if err := json.Unmarshal(fields[0], &e.Time); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields[1], &e.Type); err != nil {
+		return err
+	}
+	return json.Unmarshal(fields[2], &e.Data)
+}
+
+// ResizeData returns the data of a resize event.
+func ResizeData(cols, rows int) string {
+	return fmt.Sprintf("%dx%d", cols, rows)
+}
+
+// ParseResize parses the data of a resize event.
+func ParseResize(data string) (cols, rows int, ok bool) {
+	if _, err := fmt.Sscanf(data, "%dx%d", &cols, &rows); err != nil {
+		return 0, 0, false
+	}
+	return cols, rows, cols > 0 && rows > 0
+}
+
+// Writer writes a recording. Events written with Output and Resize are timed
+// by the clock of the writer.
+type Writer struct {
+	w     io.Writer
+	start time.Time
+	// pending is an incomplete character at the end of the last output
+	pending []byte
+}
+
+// NewWriter writes the header and starts the clock.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	h.Version = 2
+	b, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, start: time.Now()}, nil
+}
+
+// WriteEvent writes e as it is.
+func (w *Writer) WriteEvent(e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(append(b, '\n'))
+	return err
+}
+
+// Output records p as output. Events must be valid UTF-8, a character split at
+// the end of p is kept until the next call.
+func (w *Writer) Output(p []byte) error {
+	data := append(w.pending, p...)
+	end := len(data)
+	for i := len(data) - 1; i >= 0 && i > len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	w.pending = append([]byte(nil), data[end:]...)
+	if end == 0 {
+		return nil
+	}
+	return w.WriteEvent(Event{Time: w.elapsed(), Type: Output, Data: string(data[:end])})
+}
+
+// Resize records a change of the terminal size.
+func (w *Writer) Resize(cols, rows int) error {
+	return w.WriteEvent(Event{Time: w.elapsed(), Type: Resize, Data: ResizeData(cols, rows)})
+}
+
+func (w *Writer) elapsed() float64 {
+	return time.Since(w.start).Seconds()
+}
+
+// Reader reads a recording.
+type Reader struct {
+	Header  Header
+	scanner *bufio.Scanner
+}
+
+// NewReader reads the header of a recording.
+func NewReader(r io.Reader) (*Reader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("missing header")
+	}
+	reader := &Reader{scanner: scanner}
+	if err := json.Unmarshal(scanner.Bytes(), &reader.Header); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	if reader.Header.Version != 2 {
+		return nil, fmt.Errorf("unsupported version %d", reader.Header.Version)
+	}
+	return reader, nil
+}
+
+// Next returns the next event or io.EOF at the end of the recording.
+func (r *Reader) Next() (Event, error) {
+	for r.scanner.Scan() {
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return Event{}, fmt.Errorf("invalid event: %w", err)
+		}
+		return e, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
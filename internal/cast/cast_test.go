@@ -0,0 +1,106 @@
+package cast_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/cast"
+)
+
+func TestWriterReader(t *testing.T) {
+	var recording strings.Builder
+	writer, err := cast.NewWriter(&recording, cast.Header{Width: 80, Height: 24, Command: "make"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "ü" is split between two writes
+	_ = writer.Output([]byte("a\xc3"))
+	_ = writer.Output([]byte("\xbc\"\r\n"))
+	_ = writer.Resize(100, 40)
+	_ = writer.WriteEvent(cast.Event{Time: 1.5, Type: cast.Output, Data: "\x1b[31mx"})
+
+	reader, err := cast.NewReader(strings.NewReader(recording.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.Header.Version != 2 || reader.Header.Width != 80 || reader.Header.Height != 24 || reader.Header.Command != "make" {
+		t.Errorf("Unexpected header %+v", reader.Header)
+	}
+
+	expected := []cast.Event{
+		{Type: cast.Output, Data: "a"},
+		{Type: cast.Output, Data: "ü\"\r\n"},
+		{Type: cast.Resize, Data: "100x40"},
+		{Time: 1.5, Type: cast.Output, Data: "\x1b[31mx"},
+	}
+	for i, want := range expected {
+		e, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Event %d: %v", i, err)
+		}
+		if e.Type != want.Type || e.Data != want.Data || (want.Time != 0 && e.Time != want.Time) {
+			t.Errorf("Event %d: expected %+v, got %+v", i, want, e)
+		}
+	}
+	if _, err := reader.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestNewReaderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "empty", input: ""},
+		{name: "not json", input: "hello\n"},
+		{name: "version 1", input: `{"version": 1, "width": 80, "height": 24}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cast.NewReader(strings.NewReader(tt.input)); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestParseResize(t *testing.T) {
+	tests := []struct {
+		input string
+		cols  int
+		rows  int
+		ok    bool
+	}{
+		{input: "80x24", cols: 80, rows: 24, ok: true},
+		{input: "0x24"},
+		{input: "80"},
+		{input: "axb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			cols, rows, ok := cast.ParseResize(tt.input)
+			if ok != tt.ok || (ok && (cols != tt.cols || rows != tt.rows)) {
+				t.Errorf("Expected %d, %d, %v, got %d, %d, %v", tt.cols, tt.rows, tt.ok, cols, rows, ok)
+			}
+		})
+	}
+}
+
+func TestPlay(t *testing.T) {
+	recording := `{"version": 2, "width": 10, "height": 3}
+[0, "o", "one\r\ntwo\r\n"]
+[0, "o", "\u001b[1Aw"]
+`
+	var out strings.Builder
+	if err := cast.Play(strings.NewReader(recording), &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out.String(), "\x1b[?25h\x1b[?1049lone\nwwo\n") {
+		t.Errorf("Unexpected end of playback %q", out.String())
+	}
+}
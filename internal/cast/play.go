@@ -0,0 +1,126 @@
+package cast
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/ui/ansicompiler"
+)
+
+// frame is the time between two draws of the screen during playback.
+const frame = time.Second / 30
+
+// Play replays a recording to w in real time. The output is interpreted by
+// the ansicompiler and the screen it results in is drawn, so recordings of
+// other sizes don't mess up the terminal. Playback ends early when stop is
+// closed.
+func Play(r io.Reader, w io.Writer, stop <-chan struct{}) error {
+	reader, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	screen := ansicompiler.NewBuffer()
+	screen.Resize(reader.Header.Width, reader.Header.Height)
+
+	if _, err := io.WriteString(w, "\x1b[?1049h\x1b[?25l"); err != nil {
+		return err
+	}
+	start := time.Now()
+	var drawn time.Time
+	for {
+		e, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			_, _ = io.WriteString(w, "\x1b[?25h\x1b[?1049l")
+			return err
+		}
+
+		wait := time.Until(start.Add(time.Duration(e.Time * float64(time.Second))))
+		if wait > 0 {
+			// draw what happened until now before waiting for more
+			if err := draw(w, screen); err != nil {
+				return err
+			}
+			drawn = time.Now()
+			select {
+			case <-stop:
+				_, _ = io.WriteString(w, "\x1b[?25h\x1b[?1049l")
+				return nil
+			case <-time.After(wait):
+			}
+		}
+
+		switch e.Type {
+		case Output:
+			_, _ = screen.WriteString(e.Data)
+		case Resize:
+			if cols, rows, ok := ParseResize(e.Data); ok {
+				screen.Resize(cols, rows)
+			}
+		}
+		if time.Since(drawn) >= frame {
+			if err := draw(w, screen); err != nil {
+				return err
+			}
+			drawn = time.Now()
+		}
+	}
+
+	// keep the last screen in the terminal
+	_, err = fmt.Fprintf(w, "\x1b[?25h\x1b[?1049l%s\n", strings.Join(screen.ScreenLines(), "\n"))
+	return err
+}
+
+// draw replaces the terminal content with the screen
+func draw(w io.Writer, screen *ansicompiler.Buffer) error {
+	var out strings.Builder
+	out.WriteString("\x1b[H")
+	for i, line := range screen.ScreenLines() {
+		if i > 0 {
+			out.WriteString("\r\n")
+		}
+		out.WriteString(line)
+		out.WriteString("\x1b[K")
+	}
+	out.WriteString("\x1b[J")
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// Main implements `ohmygosh play <file>`.
+func Main(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: ohmygosh play <file>")
+		return 2
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ohmygosh play: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	// ctrl+c stops the playback instead of leaving the terminal on the
+	// alternate screen
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		<-interrupt
+		close(stop)
+	}()
+
+	if err := Play(f, os.Stdout, stop); err != nil {
+		fmt.Fprintf(os.Stderr, "ohmygosh play: %v\n", err)
+		return 1
+	}
+	return 0
+}
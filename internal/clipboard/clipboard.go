@@ -0,0 +1,120 @@
+// Package clipboard copies and pastes text with the clipboards listed in
+// config.Ui.Clipboard, the first one that works is used. Over SSH and in
+// containers there is no system clipboard, copies are then sent to the host
+// terminal as OSC 52 sequences and pasted from a register in memory.
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+)
+
+// Terminal is where OSC 52 sequences are written to. Stdout belongs to the
+// UI, stderr goes to the same terminal.
+var Terminal io.Writer = os.Stderr
+
+var (
+	// register is the last copied text
+	register   string
+	registerMu sync.Mutex
+)
+
+// Write copies text to the first clipboard that works. The register always
+// gets the text, so it can be pasted even if only the terminal has it.
+func Write(text string) error {
+	registerMu.Lock()
+	register = text
+	registerMu.Unlock()
+
+	var errs []error
+	for _, name := range config.Get.Ui.Clipboard {
+		var err error
+		switch name {
+		case config.ClipboardSystem:
+			err = writeSystem(text)
+		case config.ClipboardOSC52:
+			err = writeOSC52(text)
+		case config.ClipboardRegister:
+		default:
+			err = fmt.Errorf("unknown clipboard %q", name)
+		}
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return errors.New("no clipboard configured")
+	}
+	return errors.Join(errs...)
+}
+
+// Read pastes text from the first clipboard that works. The terminal can't be
+// read, it is skipped.
+func Read() (string, error) {
+	var errs []error
+	for _, name := range config.Get.Ui.Clipboard {
+		switch name {
+		case config.ClipboardSystem:
+			text, err := readSystem()
+			if err == nil {
+				return text, nil
+			}
+			errs = append(errs, err)
+		case config.ClipboardRegister:
+			registerMu.Lock()
+			text := register
+			registerMu.Unlock()
+			return text, nil
+		}
+	}
+	if len(errs) == 0 {
+		return "", errors.New("no readable clipboard configured")
+	}
+	return "", errors.Join(errs...)
+}
+
+func writeSystem(text string) error {
+	if clipboard.Unsupported {
+		return errors.New("system clipboard: not supported")
+	}
+	if err := clipboard.WriteAll(text); err != nil {
+		return fmt.Errorf("system clipboard: %w", err)
+	}
+	return nil
+}
+
+func readSystem() (string, error) {
+	if clipboard.Unsupported {
+		return "", errors.New("system clipboard: not supported")
+	}
+	text, err := clipboard.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("system clipboard: %w", err)
+	}
+	return text, nil
+}
+
+// writeOSC52 asks the terminal to copy text. Inside of tmux and screen the
+// sequence is wrapped, so it reaches the terminal around them.
+func writeOSC52(text string) error {
+	seq := osc52.New(text)
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = seq.Tmux()
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		seq = seq.Screen()
+	}
+	if _, err := seq.WriteTo(Terminal); err != nil {
+		return fmt.Errorf("osc52: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package clipboard_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/clipboard"
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+)
+
+func TestWriteRead(t *testing.T) {
+	t.Setenv("TMUX", "")
+	t.Setenv("TERM", "xterm-256color")
+	var terminal strings.Builder
+	clipboard.Terminal = &terminal
+	config.Get.Ui.Clipboard = []string{config.ClipboardOSC52, config.ClipboardRegister}
+
+	if err := clipboard.Write("hello"); err != nil {
+		t.Fatal(err)
+	}
+	// "hello" in base64
+	if expected := "\x1b]52;c;aGVsbG8=\x07"; terminal.String() != expected {
+		t.Errorf("Expected %q on the terminal, got %q", expected, terminal.String())
+	}
+	text, err := clipboard.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello" {
+		t.Errorf("Expected %q from the register, got %q", "hello", text)
+	}
+}
+
+func TestNoClipboard(t *testing.T) {
+	config.Get.Ui.Clipboard = []string{"clippy"}
+	if err := clipboard.Write("hello"); err == nil {
+		t.Error("Expected an error for an unknown clipboard")
+	}
+	if _, err := clipboard.Read(); err == nil {
+		t.Error("Expected an error without a readable clipboard")
+	}
+}
@@ -1,80 +1,189 @@
 package commands
 
 import (
-	"context"
 	"os/exec"
-	"syscall"
+	"slices"
+	"sync"
 	"time"
 )
 
-// TerminateCommand attempts to gracefully terminate a command and waits up to
-// 2 seconds. If the command doesn't exit within 2 seconds, it will be killed.
-// The function is guaranteed to return within 2 seconds and ensures the
-// command is no longer running.
-func TerminateCommand(cmd *exec.Cmd) error {
+// terminateTimeout is how long processes get to exit after SIGTERM before
+// they are killed.
+const terminateTimeout = 2 * time.Second
+
+// Signal is a signal that can be sent to a running command.
+type Signal int
+
+const (
+	// Interrupt is SIGINT, what a terminal sends on ctrl+c.
+	Interrupt Signal = iota
+	// Suspend is SIGTSTP, what a terminal sends on ctrl+z.
+	Suspend
+	// Resume is SIGCONT.
+	Resume
+	// Quit is SIGQUIT, what a terminal sends on ctrl+\.
+	Quit
+)
+
+type process struct {
+	pid    int
+	ppid   int
+	pgid   int
+	zombie bool
+}
+
+// TerminateCommand attempts to gracefully terminate a command together with
+// its process group and every process started from it. Processes still alive
+// after 2 seconds are killed and returned.
+// The function is guaranteed to return shortly after 2 seconds and ensures the
+// processes are no longer running.
+func TerminateCommand(cmd *exec.Cmd) ([]int, error) {
 	if cmd == nil {
-		return nil
+		return nil, nil
 	}
 
 	// Check if the process has even started
 	if cmd.Process == nil {
+		return nil, nil
+	}
+
+	return terminate(cmd.Process.Pid, true)
+}
+
+// SignalCommand sends sig to the process group of cmd and every process
+// started from it.
+func SignalCommand(cmd *exec.Cmd, sig Signal) error {
+	if cmd == nil || cmd.Process == nil {
 		return nil
 	}
+	return signalTree(cmd.Process.Pid, true, sig)
+}
+
+// Tracker finds the processes a shell starts for one command. Processes the
+// shell started before, like background jobs of earlier commands, are left
+// out. A shell with job control starts every job in its own process group,
+// the groups are signaled as a whole, so processes that left the tree of the
+// shell are found as well. Without job control the processes share the group
+// of the shell and only the descendants are signaled one by one.
+type Tracker struct {
+	shell int
+	// before are the processes and process groups the shell had when the
+	// command started
+	before       []int
+	beforeGroups []int
+
+	mu sync.Mutex
+	// groups are the process groups of the command seen so far
+	groups []int
+}
 
-	// Create a context with 2-second timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-
-	// Channel to signal when Wait() completes
-	waitDone := make(chan error, 1)
-
-	// Start a goroutine to wait for the process
-	go func() {
-		waitDone <- cmd.Wait()
-	}()
-
-	// Try to terminate gracefully first
-	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
-		// If we can't send SIGTERM, try to kill immediately
-		// This handles cases where the process might already be dead
-		if killErr := cmd.Process.Kill(); killErr != nil {
-			// Process might already be dead, check if Wait() completes
-			select {
-			case waitErr := <-waitDone:
-				return waitErr
-			case <-time.After(100 * time.Millisecond):
-				// If Wait() doesn't complete quickly, assume process is stuck
-				return killErr
-			}
+// Track starts tracking the processes shell starts from now on.
+func Track(shell int) *Tracker {
+	table, err := processTable()
+	if err != nil {
+		return &Tracker{shell: shell}
+	}
+	return track(table, shell)
+}
+
+func track(table []process, shell int) *Tracker {
+	t := &Tracker{shell: shell}
+	shellGroup := groupOf(table, shell)
+	t.before, _ = targets(table, shell, false)
+	for _, p := range table {
+		if slices.Contains(t.before, p.pid) && p.pgid != shellGroup && !slices.Contains(t.beforeGroups, p.pgid) {
+			t.beforeGroups = append(t.beforeGroups, p.pgid)
 		}
 	}
+	return t
+}
+
+// targets returns the processes and process groups of the command.
+func (t *Tracker) targets(table []process) (pids []int, groups []int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	children := make(map[int][]int, len(table))
+	for _, p := range table {
+		children[p.ppid] = append(children[p.ppid], p.pid)
+	}
+	shellGroup := groupOf(table, t.shell)
 
-	// Wait for either the process to exit or timeout
-	select {
-	case waitErr := <-waitDone:
-		// Process exited gracefully
-		return waitErr
-	case <-ctx.Done():
-		// Timeout reached, force kill the process
-		if killErr := cmd.Process.Kill(); killErr != nil {
-			// Kill failed, but we still need to wait for cleanup
-			select {
-			case waitErr := <-waitDone:
-				return waitErr
-			case <-time.After(500 * time.Millisecond):
-				// Give up waiting, return the kill error
-				return killErr
-			}
+	queue := slices.Clone(children[t.shell])
+	for len(queue) > 0 {
+		child := queue[0]
+		queue = queue[1:]
+		if slices.Contains(t.before, child) {
+			continue
 		}
+		pids = append(pids, child)
+		queue = append(queue, children[child]...)
+	}
+
+	for _, p := range table {
+		if !slices.Contains(pids, p.pid) || p.pgid == shellGroup {
+			continue
+		}
+		if !slices.Contains(t.beforeGroups, p.pgid) && !slices.Contains(t.groups, p.pgid) {
+			t.groups = append(t.groups, p.pgid)
+		}
+	}
+	return pids, slices.Clone(t.groups)
+}
 
-		// Wait for the killed process to be cleaned up
-		select {
-		case waitErr := <-waitDone:
-			return waitErr
-		case <-time.After(500 * time.Millisecond):
-			// Process should be dead by now, but Wait() is taking too long
-			// This is unusual but we need to return within our time constraint
-			return ctx.Err()
+// groupOf returns the process group of pid or 0 if it isn't in table.
+func groupOf(table []process, pid int) int {
+	for _, p := range table {
+		if p.pid == pid {
+			return p.pgid
+		}
+	}
+	return 0
+}
+
+// targets returns the processes to terminate for pid: its descendants, pid
+// itself if self is set and all members of process groups led by any of them.
+func targets(table []process, pid int, self bool) (pids []int, groups []int) {
+	children := make(map[int][]int, len(table))
+	for _, p := range table {
+		children[p.ppid] = append(children[p.ppid], p.pid)
+	}
+
+	if self {
+		pids = append(pids, pid)
+		// commands run in their own session, the pid is the group id
+		groups = append(groups, pid)
+	}
+	queue := slices.Clone(children[pid])
+	for len(queue) > 0 {
+		child := queue[0]
+		queue = queue[1:]
+		pids = append(pids, child)
+		queue = append(queue, children[child]...)
+	}
+
+	for _, p := range table {
+		if p.pgid == p.pid && p.pid != pid && slices.Contains(pids, p.pid) {
+			groups = append(groups, p.pgid)
+		}
+	}
+	return pids, groups
+}
+
+// alive returns the processes of pids and groups that didn't exit yet.
+func alive(pids []int, groups []int) ([]int, error) {
+	table, err := processTable()
+	if err != nil {
+		return nil, err
+	}
+	var result []int
+	for _, p := range table {
+		if p.zombie {
+			continue
+		}
+		if slices.Contains(pids, p.pid) || slices.Contains(groups, p.pgid) {
+			result = append(result, p.pid)
 		}
 	}
+	return result, nil
 }
@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTrackerTargets(t *testing.T) {
+	// the shell 100 has a background job 200 of an earlier block
+	table := []process{
+		{pid: 100, ppid: 1, pgid: 100},
+		{pid: 200, ppid: 100, pgid: 200},
+		{pid: 201, ppid: 200, pgid: 200},
+	}
+	tracker := track(table, 100)
+
+	// the run starts a job 300 that double forks 302 and a process 400 of a
+	// shell without job control, the earlier job forks 202
+	table = append(table,
+		process{pid: 202, ppid: 201, pgid: 200},
+		process{pid: 300, ppid: 100, pgid: 300},
+		process{pid: 301, ppid: 300, pgid: 300},
+		process{pid: 400, ppid: 100, pgid: 100},
+	)
+	pids, groups := tracker.targets(table)
+	slices.Sort(pids)
+	if expected := []int{300, 301, 400}; !slices.Equal(pids, expected) {
+		t.Errorf("Expected processes %v, got %v", expected, pids)
+	}
+	if expected := []int{300}; !slices.Equal(groups, expected) {
+		t.Errorf("Expected groups %v, got %v", expected, groups)
+	}
+
+	// 301 exits and leaves 302 behind, the group is still known
+	table = append(table[:5], process{pid: 302, ppid: 1, pgid: 300}, table[6])
+	pids, groups = tracker.targets(table)
+	slices.Sort(pids)
+	if expected := []int{300, 400}; !slices.Equal(pids, expected) {
+		t.Errorf("Expected processes %v, got %v", expected, pids)
+	}
+	if expected := []int{300}; !slices.Equal(groups, expected) {
+		t.Errorf("Expected groups %v, got %v", expected, groups)
+	}
+}
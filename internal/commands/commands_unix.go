@@ -0,0 +1,119 @@
+//go:build !windows
+// +build !windows
+
+package commands
+
+import (
+	"syscall"
+	"time"
+)
+
+func terminate(pid int, self bool) ([]int, error) {
+	table, err := processTable()
+	if err != nil {
+		return nil, err
+	}
+	pids, groups := targets(table, pid, self)
+	return terminateTargets(pids, groups)
+}
+
+// Terminate terminates the processes of the command the same way
+// TerminateCommand does.
+func (t *Tracker) Terminate() ([]int, error) {
+	table, err := processTable()
+	if err != nil {
+		return nil, err
+	}
+	pids, groups := t.targets(table)
+	return terminateTargets(pids, groups)
+}
+
+// Signal sends sig to the processes of the command.
+func (t *Tracker) Signal(sig Signal) error {
+	table, err := processTable()
+	if err != nil {
+		return err
+	}
+	pids, groups := t.targets(table)
+	signalTargets(pids, groups, sig)
+	return nil
+}
+
+// SignalProcess sends sig to the process pid alone.
+func SignalProcess(pid int, sig Signal) error {
+	signalTargets([]int{pid}, nil, sig)
+	return nil
+}
+
+// Running reports whether the process pid exists.
+func Running(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+func terminateTargets(pids []int, groups []int) ([]int, error) {
+	if len(pids) == 0 && len(groups) == 0 {
+		return nil, nil
+	}
+
+	signal(pids, groups, syscall.SIGTERM)
+	// stopped processes only see the SIGTERM once they continue
+	signal(pids, groups, syscall.SIGCONT)
+
+	// Wait for the processes to exit or the timeout
+	deadline := time.Now().Add(terminateTimeout)
+	for time.Now().Before(deadline) {
+		running, err := alive(pids, groups)
+		if err != nil {
+			return nil, err
+		}
+		if len(running) == 0 {
+			return nil, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Timeout reached, force kill what is left
+	survivors, err := alive(pids, groups)
+	if err != nil {
+		return nil, err
+	}
+	signal(survivors, groups, syscall.SIGKILL)
+	return survivors, nil
+}
+
+func signalTree(pid int, self bool, sig Signal) error {
+	table, err := processTable()
+	if err != nil {
+		return err
+	}
+	pids, groups := targets(table, pid, self)
+	signalTargets(pids, groups, sig)
+	return nil
+}
+
+func signalTargets(pids []int, groups []int, sig Signal) {
+	switch sig {
+	case Interrupt:
+		signal(pids, groups, syscall.SIGINT)
+	case Suspend:
+		// Blocks run in their own session, so their process groups are
+		// orphaned and the kernel discards a SIGTSTP that isn't handled.
+		// SIGSTOP makes sure they stop anyway.
+		signal(pids, groups, syscall.SIGTSTP)
+		signal(pids, groups, syscall.SIGSTOP)
+	case Resume:
+		signal(pids, groups, syscall.SIGCONT)
+	case Quit:
+		signal(pids, groups, syscall.SIGQUIT)
+	}
+}
+
+func signal(pids []int, groups []int, sig syscall.Signal) {
+	for _, pgid := range groups {
+		_ = syscall.Kill(-pgid, sig)
+	}
+	for _, pid := range pids {
+		_ = syscall.Kill(pid, sig)
+	}
+}
@@ -0,0 +1,51 @@
+//go:build windows
+// +build windows
+
+package commands
+
+import (
+	"errors"
+	"os"
+)
+
+// Windows has no process groups that can be signaled, only the process itself
+// is killed.
+func terminate(pid int, self bool) ([]int, error) {
+	if !self {
+		return nil, nil
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+	return nil, p.Kill()
+}
+
+func signalTree(int, bool, Signal) error {
+	return errors.ErrUnsupported
+}
+
+func processTable() ([]process, error) {
+	return nil, nil
+}
+
+func (t *Tracker) Terminate() ([]int, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (t *Tracker) Signal(Signal) error {
+	return errors.ErrUnsupported
+}
+
+func SignalProcess(int, Signal) error {
+	return errors.ErrUnsupported
+}
+
+func Running(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
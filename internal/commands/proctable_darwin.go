@@ -0,0 +1,39 @@
+//go:build darwin
+// +build darwin
+
+package commands
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// processTable reads all processes from ps.
+func processTable() ([]process, error) {
+	out, err := exec.Command("ps", "-A", "-o", "pid=,ppid=,pgid=,stat=").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var table []process
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		pgid, _ := strconv.Atoi(fields[2])
+		table = append(table, process{
+			pid:    pid,
+			ppid:   ppid,
+			pgid:   pgid,
+			zombie: strings.HasPrefix(fields[3], "Z"),
+		})
+	}
+	return table, nil
+}
@@ -0,0 +1,50 @@
+//go:build !windows && !darwin
+// +build !windows,!darwin
+
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// processTable reads all processes from /proc.
+func processTable() ([]process, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	table := make([]process, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			// the process exited in the meantime
+			continue
+		}
+		// pid (comm) state ppid pgrp ... where comm may contain spaces and parentheses
+		end := strings.LastIndexByte(string(stat), ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[end+1:]))
+		if len(fields) < 3 {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		pgid, _ := strconv.Atoi(fields[2])
+		table = append(table, process{
+			pid:    pid,
+			ppid:   ppid,
+			pgid:   pgid,
+			zombie: fields[0] == "Z",
+		})
+	}
+	return table, nil
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 
 	"github.com/goccy/go-yaml"
 )
@@ -40,6 +41,7 @@ type (
 
 		BorderColor               string `yaml:"border_color"`
 		BorderColorFocus          string `yaml:"border_focus_color"`
+		BorderColorSelected       string `yaml:"border_selected_color"`
 		HeaderColor               string `yaml:"header_color"`
 		HeaderCommandColorRunning string `yaml:"header_command_running_color"`
 		HeaderCommandColorDone    string `yaml:"header_command_done_color"`
@@ -49,9 +51,64 @@ type (
 		RunningColor   string `yaml:"running_color"`
 		CompletedColor string `yaml:"completed_color"`
 		FailedColor    string `yaml:"failed_color"`
+
+		// LinkOpener is the command that opens clicked links, the link is
+		// added as last argument. Only http, https and mailto links are opened.
+		LinkOpener string `yaml:"link_opener"`
+
+		// ScrollbackLines limits the lines a block keeps in memory, older
+		// lines are moved to a file in the data directory. 0 keeps all lines.
+		ScrollbackLines uint `yaml:"scrollback_lines"`
+
+		// Record keeps the raw output of every block in a file in the data
+		// directory while ohmygosh runs, the asciicast builtin exports it.
+		Record bool `yaml:"record"`
+
+		// MaxFps limits how often output of running commands is drawn.
+		MaxFps uint `yaml:"max_fps"`
+
+		// AltScreen decides what happens when a command switches to the
+		// alternate screen, one of the AltScreen policies.
+		AltScreen string `yaml:"alt_screen"`
+		// AltScreenCommands overrides AltScreen for commands by their name.
+		AltScreenCommands map[string]string `yaml:"alt_screen_commands"`
+
+		// Clipboard lists the clipboards to copy to in order of preference,
+		// copying stops at the first one that works. Pasting uses the
+		// readable ones in the same order.
+		Clipboard []string `yaml:"clipboard"`
 	}
 )
 
+// Policies for commands that switch to the alternate screen
+const (
+	// AltScreenEmbed shows the alternate screen in the block
+	AltScreenEmbed = "embed"
+	// AltScreenPassthrough hands the terminal to the running command
+	AltScreenPassthrough = "passthrough"
+	// AltScreenRestart kills the command and runs it again in the terminal
+	AltScreenRestart = "restart"
+	// AltScreenIgnore renders the alternate screen as output
+	AltScreenIgnore = "ignore"
+)
+
+// Clipboards
+const (
+	// ClipboardSystem is the clipboard of the desktop, it needs xclip,
+	// xsel or wl-clipboard on Linux
+	ClipboardSystem = "system"
+	// ClipboardOSC52 asks the terminal to copy, this works over SSH but
+	// can't be pasted from
+	ClipboardOSC52 = "osc52"
+	// ClipboardRegister keeps the text in memory for pasting in ohmygosh
+	ClipboardRegister = "register"
+)
+
+// defaultClipboard returns the default order of clipboards.
+func defaultClipboard() []string {
+	return []string{ClipboardSystem, ClipboardOSC52, ClipboardRegister}
+}
+
 var (
 	ConfigDir  string
 	ConfigFile string
@@ -59,9 +116,25 @@ var (
 
 var Get Config
 
-var Environ []string
+var (
+	Environ    []string
+	environMut sync.Mutex
+)
+
+// environIgnore lists variables that belong to the shell process reporting its
+// environment and must not be copied into Environ.
+var environIgnore = []string{
+	"_",
+	"SHLVL",
+	"PWD",
+	"OLDPWD",
+	"COLUMNS",
+	"LINES",
+}
 
 func SetEnviron(key string, value string) {
+	environMut.Lock()
+	defer environMut.Unlock()
 	for i, e := range Environ {
 		if strings.HasPrefix(e, key+"=") {
 			Environ[i] = key + "=" + os.ExpandEnv(value)
@@ -71,6 +144,99 @@ func SetEnviron(key string, value string) {
 	Environ = append(Environ, key+"="+os.ExpandEnv(value))
 }
 
+// CopyEnviron returns a copy of Environ that is safe to keep.
+func CopyEnviron() []string {
+	environMut.Lock()
+	defer environMut.Unlock()
+	return slices.Clone(Environ)
+}
+
+// SetEnvironLiteral sets key to value in Environ without expanding
+// variables in value.
+func SetEnvironLiteral(key string, value string) {
+	environMut.Lock()
+	defer environMut.Unlock()
+	for i, e := range Environ {
+		if strings.HasPrefix(e, key+"=") {
+			Environ[i] = key + "=" + value
+			return
+		}
+	}
+	Environ = append(Environ, key+"="+value)
+}
+
+// LookupEnviron returns the value of key in Environ.
+func LookupEnviron(key string) (string, bool) {
+	environMut.Lock()
+	defer environMut.Unlock()
+	for _, e := range Environ {
+		if value, ok := strings.CutPrefix(e, key+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// UnsetEnviron removes key from Environ.
+func UnsetEnviron(key string) {
+	environMut.Lock()
+	defer environMut.Unlock()
+	Environ = slices.DeleteFunc(Environ, func(e string) bool {
+		return strings.HasPrefix(e, key+"=")
+	})
+}
+
+// ApplyEnviron takes over the changes a command made to its environment.
+// start is the environment the command started with, env the one it reported
+// when it finished. Variables it removed are removed, new and changed ones are
+// taken over as they are. Changes made by others while it ran are kept.
+func ApplyEnviron(start []string, env []string) {
+	environMut.Lock()
+	defer environMut.Unlock()
+
+	before := reportedEnviron(start)
+	after := reportedEnviron(env)
+
+	Environ = slices.DeleteFunc(Environ, func(e string) bool {
+		key, _, _ := strings.Cut(e, "=")
+		_, started := before[key]
+		_, reported := after[key]
+		return started && !reported
+	})
+	for _, e := range env {
+		key, _, _ := strings.Cut(e, "=")
+		value, ok := after[key]
+		if !ok {
+			continue
+		}
+		if old, ok := before[key]; ok && old == value {
+			continue
+		}
+		i := slices.IndexFunc(Environ, func(e string) bool {
+			return strings.HasPrefix(e, key+"=")
+		})
+		if i < 0 {
+			Environ = append(Environ, key+"="+value)
+		} else {
+			Environ[i] = key + "=" + value
+		}
+	}
+}
+
+// reportedEnviron returns the variables of env that may be taken over into
+// Environ by name.
+func reportedEnviron(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, e := range env {
+		key, value, ok := strings.Cut(e, "=")
+		if !ok || key == "" || slices.Contains(environIgnore, key) {
+			continue
+		}
+		m[key] = value
+	}
+	return m
+}
+
 func Default() Config {
 	shell := GetSystemShell()
 	return Config{
@@ -93,6 +259,7 @@ func Default() Config {
 			VisualSelectionBg:         "8",
 			BorderColor:               "8",
 			BorderColorFocus:          "5",
+			BorderColorSelected:       "6",
 			HeaderColor:               "8",
 			HeaderCommandColorRunning: "7",
 			HeaderCommandColorDone:    "8",
@@ -101,6 +268,12 @@ func Default() Config {
 			RunningColor:              "4",
 			CompletedColor:            "2",
 			FailedColor:               "1",
+			LinkOpener:                defaultLinkOpener(),
+			ScrollbackLines:           10000,
+			MaxFps:                    60,
+			AltScreen:                 AltScreenEmbed,
+			AltScreenCommands:         map[string]string{},
+			Clipboard:                 defaultClipboard(),
 		},
 	}
 }
@@ -115,9 +288,31 @@ func (c *Config) Load(path string) error {
 	if err != nil {
 		return err
 	}
+	return c.validate()
+}
+
+// validate rejects settings that only allow some values.
+func (c *Config) validate() error {
+	if c.Ui.AltScreen != "" && !validAltScreen(c.Ui.AltScreen) {
+		return fmt.Errorf("ui.alt_screen: unknown policy %q", c.Ui.AltScreen)
+	}
+	for name, policy := range c.Ui.AltScreenCommands {
+		if !validAltScreen(policy) {
+			return fmt.Errorf("ui.alt_screen_commands.%s: unknown policy %q", name, policy)
+		}
+	}
 	return nil
 }
 
+func validAltScreen(policy string) bool {
+	switch policy {
+	case AltScreenEmbed, AltScreenPassthrough, AltScreenRestart, AltScreenIgnore:
+		return true
+	default:
+		return false
+	}
+}
+
 func (c *Config) Save(path string) error {
 	f, err := os.Create(path)
 	if err != nil {
@@ -162,6 +357,26 @@ func init() {
 			Get.Shell.Completion = Get.Shell.Exe
 		}
 
+		if Get.Ui.LinkOpener == "" {
+			Get.Ui.LinkOpener = defaultLinkOpener()
+		}
+
+		if Get.Ui.MaxFps == 0 {
+			Get.Ui.MaxFps = 60
+		}
+
+		if Get.Ui.BorderColorSelected == "" {
+			Get.Ui.BorderColorSelected = "6"
+		}
+
+		if Get.Ui.AltScreen == "" {
+			Get.Ui.AltScreen = AltScreenEmbed
+		}
+
+		if len(Get.Ui.Clipboard) == 0 {
+			Get.Ui.Clipboard = defaultClipboard()
+		}
+
 		switch Get.Ui.NormalColorBg {
 		case "0":
 			Get.Ui.NormalColorFg = "7"
@@ -11,3 +11,7 @@ import (
 func defaultConfigDir() string {
 	return filepath.Join(os.Getenv("HOME"), ".config", "ohmygosh")
 }
+
+func defaultLinkOpener() string {
+	return "xdg-open"
+}
@@ -11,3 +11,7 @@ import (
 func defaultConfigDir() string {
 	return filepath.Join(os.Getenv("HOME"), "Library", "Application Support", "ohmygosh")
 }
+
+func defaultLinkOpener() string {
+	return "open"
+}
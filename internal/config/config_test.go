@@ -0,0 +1,52 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+)
+
+func TestLoadAltScreen(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{"policy", "ui:\n  alt_screen: passthrough\n", false},
+		{"command policy", "ui:\n  alt_screen_commands:\n    vim: restart\n", false},
+		{"unknown policy", "ui:\n  alt_screen: fullscreen\n", true},
+		{"unknown command policy", "ui:\n  alt_screen_commands:\n    vim: Embed\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0600); err != nil {
+				t.Fatal(err)
+			}
+			c := config.Default()
+			if err := c.Load(path); (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyEnviron(t *testing.T) {
+	config.Environ = []string{"KEEP=1", "CHANGE=1", "REMOVE=1", "OTHER=1"}
+	start := config.CopyEnviron()
+
+	// other blocks change the environment while the command runs
+	config.SetEnvironLiteral("OTHER", "2")
+	config.SetEnvironLiteral("NEW", "1")
+	config.UnsetEnviron("KEEP")
+
+	config.ApplyEnviron(start, []string{"KEEP=1", "CHANGE=2", "OTHER=1", "ADD=1", "SHLVL=2"})
+
+	want := []string{"CHANGE=2", "OTHER=2", "NEW=1", "ADD=1"}
+	if !slices.Equal(config.Environ, want) {
+		t.Errorf("Environ = %q, want %q", config.Environ, want)
+	}
+}
@@ -25,3 +25,7 @@ func GetSystemShell() string {
 func defaultConfigDir() string {
 	return filepath.Join(os.Getenv("APPDATA"), "ohmygosh")
 }
+
+func defaultLinkOpener() string {
+	return "rundll32 url.dll,FileProtocolHandler"
+}
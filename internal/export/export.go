@@ -0,0 +1,174 @@
+// Package export renders command blocks as self-contained HTML, GitHub
+// flavoured Markdown or plain text, for sharing them outside of the terminal.
+package export
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/tsukinoko-kun/ohmygosh/internal/ui/ansicompiler"
+)
+
+// Format is an output format of an export.
+type Format string
+
+const (
+	HTML     Format = "html"
+	Markdown Format = "markdown"
+	Text     Format = "text"
+	// Output is the output of the blocks as plain text, without their
+	// commands
+	Output Format = "output"
+)
+
+// ParseFormat parses the name of a format, the usual file extensions are
+// accepted too.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "html", "htm":
+		return HTML, nil
+	case "markdown", "md":
+		return Markdown, nil
+	case "text", "txt", "plain":
+		return Text, nil
+	case "output":
+		return Output, nil
+	}
+	return "", fmt.Errorf("unknown format %q", s)
+}
+
+// FormatOf returns the format that fits the extension of path. Anything
+// unknown is exported as text.
+func FormatOf(path string) Format {
+	if f, err := ParseFormat(strings.TrimPrefix(filepath.Ext(path), ".")); err == nil {
+		return f
+	}
+	return Text
+}
+
+// Block is a command block as it is exported.
+type Block struct {
+	Prompt  string
+	Command string
+	// Running is set for blocks that didn't finish yet, they have no exit
+	// code and duration
+	Running  bool
+	ExitCode int
+	Duration time.Duration
+	// Output is the rendered output of the block, one line per row
+	Output string
+}
+
+// Status describes how the block ended and how long it ran.
+func (b Block) Status() string {
+	switch {
+	case b.Running:
+		return "running"
+	case b.ExitCode != 0:
+		return fmt.Sprintf("✗ %d (%s)", b.ExitCode, RoundDuration(b.Duration))
+	}
+	return fmt.Sprintf("✓ (%s)", RoundDuration(b.Duration))
+}
+
+// RoundDuration rounds how long a command ran for display, to seconds for long
+// commands and to milliseconds for short ones.
+func RoundDuration(d time.Duration) time.Duration {
+	if d > 3*time.Second {
+		return d.Round(time.Second)
+	}
+	return d.Round(time.Millisecond)
+}
+
+// Write renders blocks in format to w.
+func Write(w io.Writer, format Format, blocks []Block) error {
+	var out string
+	switch format {
+	case HTML:
+		out = renderHTML(blocks)
+	case Markdown:
+		out = renderMarkdown(blocks)
+	case Text:
+		out = renderText(blocks)
+	case Output:
+		out = renderOutput(blocks)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+// rows returns the cells of every line of the output. The lines are rendered
+// rows of a buffer, so every line can be compiled on its own.
+func rows(output string) [][]ansicompiler.Cell {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	cells := make([][]ansicompiler.Cell, len(lines))
+	for i, line := range lines {
+		// as wide as the line, so nothing wraps
+		buffer := ansicompiler.NewBuffer()
+		buffer.Resize(ansi.StringWidth(line), 1)
+		_, _ = buffer.WriteString(line)
+		cells[i] = trimRow(buffer.Cells(0))
+	}
+	return cells
+}
+
+// trimRow drops the empty cells at the end of row.
+func trimRow(row []ansicompiler.Cell) []ansicompiler.Cell {
+	for len(row) > 0 {
+		last := row[len(row)-1]
+		if last.Rune != ' ' || last.Style != (ansicompiler.Style{}) {
+			break
+		}
+		row = row[:len(row)-1]
+	}
+	return row
+}
+
+// cellText returns the text a cell is drawn with. The right half of a wide
+// character is drawn by the left one, a half without the other is a space.
+func cellText(row []ansicompiler.Cell, i int) string {
+	cell := row[i]
+	if cell.Continuation && i > 0 && row[i-1].Wide {
+		return ""
+	}
+	if cell.Continuation || (cell.Wide && (i+1 >= len(row) || !row[i+1].Continuation)) {
+		return " "
+	}
+	return cell.Text()
+}
+
+// rowText returns the text of a row without styles.
+func rowText(row []ansicompiler.Cell) string {
+	var text strings.Builder
+	for i := range row {
+		text.WriteString(cellText(row, i))
+	}
+	return strings.TrimRight(text.String(), " ")
+}
+
+// PlainText returns output without escape sequences, as it is drawn.
+func PlainText(output string) string {
+	lines := plainLines(output)
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// plainLines returns the output of a block without styles.
+func plainLines(output string) []string {
+	cells := rows(output)
+	lines := make([]string, len(cells))
+	for i, row := range cells {
+		lines[i] = rowText(row)
+	}
+	return lines
+}
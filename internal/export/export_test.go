@@ -0,0 +1,117 @@
+package export_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/export"
+)
+
+var block = export.Block{
+	Prompt:   "~/src",
+	Command:  "ls",
+	Duration: 12 * time.Millisecond,
+	Output:   "\x1b[1;31mred\x1b[0m <b>\n\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\\n",
+}
+
+func render(t *testing.T, format export.Format, blocks ...export.Block) string {
+	t.Helper()
+	var out strings.Builder
+	if err := export.Write(&out, format, blocks); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestText(t *testing.T) {
+	expected := "~/src\n$ ls\nred <b>\nlink\n✓ (12ms)\n"
+	if got := render(t, export.Text, block); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	expected := "`~/src` ✓ (12ms)\n\n```console\n$ ls\nred <b>\nlink\n```\n"
+	if got := render(t, export.Markdown, block); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	fenced := export.Block{Command: "cat README.md", ExitCode: 1, Duration: 3 * time.Millisecond, Output: "```go\n```\n"}
+	got := render(t, export.Markdown, fenced)
+	if !strings.HasPrefix(got, "✗ 1 (3ms)\n\n````console\n") || !strings.HasSuffix(got, "\n````\n") {
+		t.Errorf("Expected a longer fence than the output, got %q", got)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	expected := "red <b>\nlink\nred <b>\nlink\n"
+	if got := render(t, export.Output, block, block); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	got := render(t, export.HTML, block)
+	for _, want := range []string{
+		"<title>ls</title>",
+		`<span style="color:#cd0000;font-weight:bold">red</span> &lt;b&gt;`,
+		`<a href="https://example.com">link</a>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in %s", want, got)
+		}
+	}
+
+	for _, link := range []string{"javascript:alert(1)", "file:///etc/passwd"} {
+		unsafe := export.Block{Command: "x", Output: "\x1b]8;;" + link + "\x1b\\x\x1b]8;;\x1b\\\n"}
+		if got := render(t, export.HTML, unsafe); strings.Contains(got, link) {
+			t.Errorf("Expected the link %q to be dropped, got %s", link, got)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		block    export.Block
+		expected string
+	}{
+		{export.Block{Running: true}, "running"},
+		{export.Block{Duration: 1234567 * time.Microsecond}, "✓ (1.235s)"},
+		{export.Block{Duration: 4400 * time.Millisecond}, "✓ (4s)"},
+		{export.Block{ExitCode: 2, Duration: 12 * time.Millisecond}, "✗ 2 (12ms)"},
+	}
+	for _, tt := range tests {
+		if got := tt.block.Status(); got != tt.expected {
+			t.Errorf("%+v: expected %q, got %q", tt.block, tt.expected, got)
+		}
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]export.Format{
+		"out.html": export.HTML,
+		"out.MD":   export.Markdown,
+		"out.txt":  export.Text,
+		"out.log":  export.Text,
+		"out":      export.Text,
+	}
+	for path, expected := range tests {
+		if got := export.FormatOf(path); got != expected {
+			t.Errorf("%s: expected %s, got %s", path, expected, got)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := map[string]string{
+		"":                       "",
+		"\x1b[32mok\x1b[0m   \n": "ok\n",
+		"a\n\x1b]8;;https://x\x1b\\x\x1b]8;;\x1b\\": "a\nx\n",
+	}
+	for output, expected := range tests {
+		if got := export.PlainText(output); got != expected {
+			t.Errorf("%q: expected %q, got %q", output, expected, got)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/ui/ansicompiler"
+)
+
+// palette are the 16 basic colors, the ones of xterm
+var palette = [16]string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+const htmlHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+:root { --fg: #d4d4d4; --bg: #1e1e1e; }
+body { margin: 0; padding: 1em; color: var(--fg); background: var(--bg); font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 14px; }
+.block { margin-bottom: 1.5em; border-left: 3px solid #555; padding-left: .75em; }
+.prompt { color: #888; }
+.status { color: #0a0; }
+.failed .status { color: #d33; }
+.running .status { color: #cc0; }
+pre { margin: .5em 0 0; font: inherit; line-height: 1.2; white-space: pre; }
+a { color: inherit; }
+</style>
+</head>
+<body>
+`
+
+func renderHTML(blocks []Block) string {
+	title := "ohmygosh"
+	if len(blocks) == 1 {
+		title = blocks[0].Command
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, htmlHead, html.EscapeString(title))
+	for _, b := range blocks {
+		class := "block"
+		if b.Running {
+			class += " running"
+		} else if b.ExitCode != 0 {
+			class += " failed"
+		}
+		fmt.Fprintf(&out, "<section class=%q>\n", class)
+		if b.Prompt != "" {
+			fmt.Fprintf(&out, "<div class=\"prompt\">%s</div>\n", html.EscapeString(b.Prompt))
+		}
+		fmt.Fprintf(&out, "<div class=\"command\"><span class=\"status\">%s</span> %s</div>\n",
+			html.EscapeString(b.Status()), html.EscapeString(b.Command))
+		if cells := rows(b.Output); len(cells) > 0 {
+			out.WriteString("<pre class=\"output\">")
+			for i, row := range cells {
+				if i > 0 {
+					out.WriteByte('\n')
+				}
+				writeRowHTML(&out, row)
+			}
+			out.WriteString("</pre>\n")
+		}
+		out.WriteString("</section>\n")
+	}
+	out.WriteString("</body>\n</html>\n")
+	return out.String()
+}
+
+// writeRowHTML writes a row as spans of cells with the same style, cells with
+// a link are wrapped in an anchor.
+func writeRowHTML(out *strings.Builder, row []ansicompiler.Cell) {
+	var link string
+	for i := 0; i < len(row); {
+		style, cellLink := row[i].Style, row[i].Link
+		var text strings.Builder
+		for ; i < len(row) && row[i].Style == style && row[i].Link == cellLink; i++ {
+			text.WriteString(cellText(row, i))
+		}
+
+		if cellLink != link {
+			if link != "" {
+				out.WriteString("</a>")
+			}
+			link = ""
+			if ansicompiler.SafeLink(cellLink) {
+				link = cellLink
+				fmt.Fprintf(out, "<a href=\"%s\">", html.EscapeString(link))
+			}
+		}
+		if css := styleCSS(style); css != "" {
+			fmt.Fprintf(out, "<span style=\"%s\">%s</span>", css, html.EscapeString(text.String()))
+		} else {
+			out.WriteString(html.EscapeString(text.String()))
+		}
+	}
+	if link != "" {
+		out.WriteString("</a>")
+	}
+}
+
+// styleCSS converts the style of a cell to inline CSS.
+func styleCSS(s ansicompiler.Style) string {
+	var css []string
+
+	fg, bg := colorCSS(s.Fg), colorCSS(s.Bg)
+	if s.Attrs&ansicompiler.AttrReverse != 0 {
+		fg, bg = bg, fg
+		if fg == "" {
+			fg = "var(--bg)"
+		}
+		if bg == "" {
+			bg = "var(--fg)"
+		}
+	}
+	if fg != "" {
+		css = append(css, "color:"+fg)
+	}
+	if bg != "" {
+		css = append(css, "background:"+bg)
+	}
+
+	if s.Attrs&ansicompiler.AttrBold != 0 {
+		css = append(css, "font-weight:bold")
+	}
+	if s.Attrs&ansicompiler.AttrDim != 0 {
+		css = append(css, "opacity:.6")
+	}
+	if s.Attrs&ansicompiler.AttrItalic != 0 {
+		css = append(css, "font-style:italic")
+	}
+	if s.Attrs&ansicompiler.AttrHidden != 0 {
+		css = append(css, "visibility:hidden")
+	}
+
+	var lines []string
+	if s.Underline != ansicompiler.UnderlineNone {
+		lines = append(lines, "underline")
+	}
+	if s.Attrs&ansicompiler.AttrStrike != 0 {
+		lines = append(lines, "line-through")
+	}
+	if s.Attrs&ansicompiler.AttrOverline != 0 {
+		lines = append(lines, "overline")
+	}
+	if len(lines) > 0 {
+		css = append(css, "text-decoration:"+strings.Join(lines, " "))
+		switch s.Underline {
+		case ansicompiler.UnderlineDouble:
+			css = append(css, "text-decoration-style:double")
+		case ansicompiler.UnderlineCurly:
+			css = append(css, "text-decoration-style:wavy")
+		case ansicompiler.UnderlineDotted:
+			css = append(css, "text-decoration-style:dotted")
+		case ansicompiler.UnderlineDashed:
+			css = append(css, "text-decoration-style:dashed")
+		}
+		if color := colorCSS(s.UnderlineColor); color != "" {
+			css = append(css, "text-decoration-color:"+color)
+		}
+	}
+
+	return strings.Join(css, ";")
+}
+
+// colorCSS returns the CSS color of c or an empty string for the default
+// color.
+func colorCSS(c ansicompiler.Color) string {
+	switch c.Kind {
+	case ansicompiler.ColorBasic:
+		return palette[c.Index%16]
+	case ansicompiler.ColorIndexed:
+		return indexedColor(c.Index)
+	case ansicompiler.ColorRGB:
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return ""
+}
+
+// indexedColor returns a color of the 256 color palette: the basic colors,
+// a 6x6x6 color cube and 24 shades of gray.
+func indexedColor(i uint8) string {
+	switch {
+	case i < 16:
+		return palette[i]
+	case i < 232:
+		levels := [6]uint8{0, 95, 135, 175, 215, 255}
+		i -= 16
+		return fmt.Sprintf("#%02x%02x%02x", levels[i/36], levels[i/6%6], levels[i%6])
+	}
+	gray := 8 + 10*(i-232)
+	return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+}
@@ -0,0 +1,62 @@
+package export
+
+import (
+	"strings"
+)
+
+// renderMarkdown renders every block as a line with the prompt and the status
+// followed by the command and the output in a fenced code block.
+func renderMarkdown(blocks []Block) string {
+	var out strings.Builder
+	for i, b := range blocks {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		lines := append([]string{"$ " + b.Command}, plainLines(b.Output)...)
+		fence := fenceFor(lines)
+
+		if b.Prompt != "" {
+			out.WriteString(inlineCode(b.Prompt) + " ")
+		}
+		out.WriteString(b.Status() + "\n\n")
+		out.WriteString(fence + "console\n")
+		for _, line := range lines {
+			out.WriteString(line + "\n")
+		}
+		out.WriteString(fence + "\n")
+	}
+	return out.String()
+}
+
+// fenceFor returns a code fence that is longer than any run of backticks at
+// the start of lines, so the code block can't be closed by its content.
+func fenceFor(lines []string) string {
+	longest := 0
+	for _, line := range lines {
+		for _, part := range strings.Split(line, "\n") {
+			part = strings.TrimLeft(part, " ")
+			n := len(part) - len(strings.TrimLeft(part, "`"))
+			longest = max(longest, n)
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// inlineCode wraps s in backticks, using more of them than s contains in a
+// row.
+func inlineCode(s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	ticks := strings.Repeat("`", longest+1)
+	if longest > 0 {
+		return ticks + " " + s + " " + ticks
+	}
+	return ticks + s + ticks
+}
@@ -0,0 +1,36 @@
+package export
+
+import (
+	"strings"
+)
+
+// renderText renders every block as the prompt, the command, the output
+// without styles and the status.
+func renderText(blocks []Block) string {
+	var out strings.Builder
+	for i, b := range blocks {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		if b.Prompt != "" {
+			out.WriteString(b.Prompt + "\n")
+		}
+		out.WriteString("$ " + b.Command + "\n")
+		for _, line := range plainLines(b.Output) {
+			out.WriteString(line + "\n")
+		}
+		out.WriteString(b.Status() + "\n")
+	}
+	return out.String()
+}
+
+// renderOutput renders the output of every block without styles.
+func renderOutput(blocks []Block) string {
+	var out strings.Builder
+	for _, b := range blocks {
+		for _, line := range plainLines(b.Output) {
+			out.WriteString(line + "\n")
+		}
+	}
+	return out.String()
+}
@@ -19,6 +19,14 @@ type history []string
 
 var historyFile = filepath.Join(data.Path, "history.txt")
 
+// header marks a history file that stores one escaped entry per line, a
+// backslash is stored as `\\` and a newline as `\n`.
+// Files without it are from older versions, they store multi-line entries
+// with a backslash at the end of every line but the last one.
+const header = "#ohmygosh history v2"
+
+const continuation = "\\"
+
 func open() history {
 	f, err := os.Open(historyFile)
 	if err != nil {
@@ -34,10 +42,31 @@ func open() history {
 	}
 	defer f.Close()
 
-	var lines []string
+	var (
+		lines   []string
+		pending []string
+		escaped bool
+	)
 	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	for i := 0; scanner.Scan(); i++ {
+		line := scanner.Text()
+		if i == 0 && line == header {
+			escaped = true
+			continue
+		}
+		if escaped {
+			lines = append(lines, unescape(line))
+			continue
+		}
+		if rest, ok := strings.CutSuffix(line, continuation); ok {
+			pending = append(pending, rest)
+			continue
+		}
+		lines = append(lines, strings.Join(append(pending, line), "\n"))
+		pending = nil
+	}
+	if len(pending) > 0 {
+		lines = append(lines, strings.Join(pending, "\n"))
 	}
 	return lines
 }
@@ -49,17 +78,32 @@ func (h history) Close() {
 	}
 	defer f.Close()
 
+	if _, err := f.WriteString(header + "\n"); err != nil {
+		return
+	}
 	for _, line := range h {
-		if _, err := f.WriteString(line); err != nil {
-			return
-		}
-		if _, err := f.WriteString("\n"); err != nil {
+		if _, err := f.WriteString(escape(line) + "\n"); err != nil {
 			return
 		}
 	}
 	_ = f.Sync()
 }
 
+var (
+	escaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	unescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+)
+
+// escape returns line as a single line of the history file.
+func escape(line string) string {
+	return escaper.Replace(line)
+}
+
+// unescape reverses escape.
+func unescape(line string) string {
+	return unescaper.Replace(line)
+}
+
 func Push(line string) {
 	if config.Get.Shell.MaxHistoryLength == 0 {
 		return
@@ -135,3 +179,18 @@ func PeekReverse() string {
 	peekIndex = -1
 	return ""
 }
+
+// All returns every stored line, oldest first.
+func All() []string {
+	if config.Get.Shell.MaxHistoryLength == 0 {
+		return nil
+	}
+	return open()
+}
+
+// Clear removes all stored lines.
+func Clear() {
+	filter = ""
+	peekIndex = -1
+	history(nil).Close()
+}
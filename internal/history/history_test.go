@@ -0,0 +1,43 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	historyFile = filepath.Join(t.TempDir(), "history.txt")
+
+	want := history{
+		"ls -la",
+		"for i in 1 2; do\n  echo $i\ndone",
+		`echo foo \`,
+		`printf 'a\nb\\'`,
+		"",
+	}
+	want.Close()
+
+	if got := open(); !slices.Equal(got, want) {
+		t.Errorf("open() = %q, want %q", got, want)
+	}
+}
+
+func TestOldFile(t *testing.T) {
+	historyFile = filepath.Join(t.TempDir(), "history.txt")
+
+	old := "ls -la\nfor i in 1 2; do\\\n  echo $i\\\ndone\nprintf 'a\\n'\n"
+	if err := os.WriteFile(historyFile, []byte(old), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"ls -la",
+		"for i in 1 2; do\n  echo $i\ndone",
+		`printf 'a\n'`,
+	}
+	if got := open(); !slices.Equal(got, want) {
+		t.Errorf("open() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,216 @@
+// Package ipc implements the protocol between ohmygosh and the shells it runs.
+//
+// The server listens on a Unix domain socket in a directory that only the
+// current user can access. A request is a single connection that sends the
+// key, the command line and an optional payload. The key and the argument are
+// quoted like Go strings, so they can't contain a newline:
+//
+//	"<key>"\n
+//	<command> "<argument>"\n
+//	<payload until EOF>
+//
+// The server answers with "ok\n" or "error <message>\n".
+// Shells talk to the server through the built-in client `ohmygosh ipc`.
+package ipc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// EnvSocket is the environment variable holding the socket path for the
+	// client.
+	EnvSocket = "OHMYGOSH_IPC"
+	// EnvKey is the environment variable holding the key for the client.
+	EnvKey = "OHMYGOSH_IPC_KEY"
+)
+
+// Handler processes a single request. The returned error is sent back to the
+// client.
+type Handler func(cmd string, arg string, payload []byte) error
+
+// Listen creates the socket at path, replacing a stale one left behind by a
+// crashed session. Only the current user may connect to it: the directory of
+// the socket is created if needed and must not be accessible by others, so
+// nobody can connect before the socket itself is restricted.
+func Listen(path string) (net.Listener, error) {
+	if err := privateDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// Serve accepts requests on ln until it is closed.
+func Serve(ln net.Listener, key string, handler Handler) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go serveConn(conn, key, handler)
+	}
+}
+
+func serveConn(conn net.Conn, key string, handler Handler) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reqKey, err := r.ReadString('\n')
+	if err != nil {
+		return
+	}
+	if reqKey, err := strconv.Unquote(strings.TrimSuffix(reqKey, "\n")); err != nil || reqKey != key {
+		_, _ = io.WriteString(conn, "error unauthorized\n")
+		return
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		_, _ = io.WriteString(conn, "error no command\n")
+		return
+	}
+	cmd, quotedArg, _ := strings.Cut(strings.TrimSuffix(line, "\n"), " ")
+	if cmd == "" {
+		_, _ = io.WriteString(conn, "error no command\n")
+		return
+	}
+	arg, err := strconv.Unquote(quotedArg)
+	if err != nil {
+		_, _ = io.WriteString(conn, "error malformed argument\n")
+		return
+	}
+
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		_, _ = fmt.Fprintf(conn, "error %v\n", err)
+		return
+	}
+
+	if err := handler(cmd, arg, payload); err != nil {
+		_, _ = fmt.Fprintf(conn, "error %v\n", strings.ReplaceAll(err.Error(), "\n", " "))
+		return
+	}
+	_, _ = io.WriteString(conn, "ok\n")
+}
+
+// Send sends a request to the server found in the environment.
+func Send(cmd string, arg string, payload []byte) error {
+	path, ok := os.LookupEnv(EnvSocket)
+	if !ok {
+		return fmt.Errorf("%s is not set", EnvSocket)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	w := bufio.NewWriter(conn)
+	_, _ = fmt.Fprintf(w, "%s\n%s %s\n", strconv.Quote(os.Getenv(EnvKey)), cmd, strconv.Quote(arg))
+	_, _ = w.Write(payload)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if c, ok := conn.(*net.UnixConn); ok {
+		_ = c.CloseWrite()
+	}
+
+	resp, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if msg, ok := strings.CutPrefix(strings.TrimSuffix(resp, "\n"), "error "); ok {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// Main is the entry point of `ohmygosh ipc <command>`.
+// It returns the exit code for the process.
+func Main(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ohmygosh ipc <exit|cd|env|sync> [argument]")
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "exit":
+		code := ""
+		if len(args) > 1 {
+			code = args[1]
+		}
+		err = Send("exit", code, nil)
+	case "cd":
+		err = sendWd(args[1:])
+	case "env":
+		err = sendEnv(args[1:])
+	case "sync":
+		if err = sendWd(nil); err == nil {
+			err = sendEnv(args[1:])
+		}
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ohmygosh ipc: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// sendWd reports the given directory or the working directory of the client,
+// which it inherited from the shell.
+func sendWd(args []string) error {
+	if len(args) > 0 {
+		return Send("cd", args[0], nil)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	return Send("cd", wd, nil)
+}
+
+// sendEnv reports the environment of the client, which is the exported
+// environment of the shell that started it, as NUL separated list.
+// The argument is the block whose command finished, if any.
+func sendEnv(args []string) error {
+	id := ""
+	if len(args) > 0 {
+		id = args[0]
+	}
+	var payload strings.Builder
+	for _, e := range os.Environ() {
+		if strings.HasPrefix(e, EnvSocket+"=") || strings.HasPrefix(e, EnvKey+"=") {
+			continue
+		}
+		payload.WriteString(e)
+		payload.WriteByte(0)
+	}
+	return Send("env", id, []byte(payload.String()))
+}
@@ -0,0 +1,103 @@
+package ipc_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/ipc"
+)
+
+type request struct {
+	cmd     string
+	arg     string
+	payload string
+}
+
+// serve starts a server with key in a new directory and points the client at
+// it. Requests are sent to the returned channel.
+func serve(t *testing.T, key string) (string, <-chan request) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ipc", "test.sock")
+	ln, err := ipc.Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	requests := make(chan request, 1)
+	go func() {
+		_ = ipc.Serve(ln, key, func(cmd string, arg string, payload []byte) error {
+			requests <- request{cmd: cmd, arg: arg, payload: string(payload)}
+			return nil
+		})
+	}()
+
+	t.Setenv(ipc.EnvSocket, path)
+	t.Setenv(ipc.EnvKey, key)
+	return path, requests
+}
+
+func TestRoundTrip(t *testing.T) {
+	_, requests := serve(t, "secret")
+
+	tests := []request{
+		{cmd: "cd", arg: "/tmp/with space"},
+		{cmd: "cd", arg: "/tmp/new\nline"},
+		{cmd: "exit", arg: ""},
+		{cmd: "env", arg: "", payload: "A=1\x00B=two\nlines\x00"},
+	}
+	for _, tt := range tests {
+		if err := ipc.Send(tt.cmd, tt.arg, []byte(tt.payload)); err != nil {
+			t.Fatalf("%s %q: %v", tt.cmd, tt.arg, err)
+		}
+		if got := <-requests; got != tt {
+			t.Errorf("Expected %q, got %q", tt, got)
+		}
+	}
+}
+
+func TestBadKey(t *testing.T) {
+	_, requests := serve(t, "secret")
+	t.Setenv(ipc.EnvKey, "guess")
+
+	if err := ipc.Send("cd", "/", nil); err == nil || err.Error() != "unauthorized" {
+		t.Fatalf("Expected unauthorized, got %v", err)
+	}
+	select {
+	case r := <-requests:
+		t.Errorf("Expected no request to be handled, got %q", r)
+	default:
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	path, _ := serve(t, "secret")
+
+	dir, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := dir.Mode().Perm(); perm != 0700 {
+		t.Errorf("Expected the socket directory to have mode 0700, got %o", perm)
+	}
+	socket, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := socket.Mode().Perm(); perm != 0600 {
+		t.Errorf("Expected the socket to have mode 0600, got %o", perm)
+	}
+
+	shared := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(shared, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if ln, err := ipc.Listen(filepath.Join(shared, "test.sock")); err == nil {
+		_ = ln.Close()
+		t.Error("Expected a directory other users can access to be refused")
+	}
+}
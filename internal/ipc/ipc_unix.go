@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package ipc
+
+import (
+	"fmt"
+	"os"
+)
+
+// privateDir creates dir for the socket and makes sure only the current user
+// can access it.
+func privateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%s is accessible by other users", dir)
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package ipc
+
+import (
+	"os"
+)
+
+// privateDir creates dir for the socket. Windows has no permission bits, the
+// data directory is private to the user already.
+func privateDir(dir string) error {
+	return os.MkdirAll(dir, 0700)
+}
@@ -0,0 +1,34 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+)
+
+// Expand returns the words of the simple command line after the expansions
+// of a POSIX shell, like the arguments a program would get for it. Only the
+// environment is known to the shell, not the variables of the session.
+func Expand(line string) ([]string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", `printf '%s\0' `+line)
+	cmd.Env = config.CopyEnviron()
+	cmd.Dir = Wd
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	out := strings.TrimSuffix(stdout.String(), "\x00")
+	if out == "" && stdout.Len() == 0 {
+		return nil, nil
+	}
+	return strings.Split(out, "\x00"), nil
+}
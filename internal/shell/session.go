@@ -0,0 +1,400 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/tsukinoko-kun/ohmygosh/internal/commands"
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+)
+
+var (
+	// ErrSessionBusy is returned by RunInSession while another block is still
+	// running inside the session shell.
+	ErrSessionBusy = errors.New("shell session is busy")
+	// ErrSessionUnsupported is returned by RunInSession if the configured shell
+	// can't be driven as a session (non POSIX shells).
+	ErrSessionUnsupported = errors.New("shell does not support sessions")
+)
+
+// Session is a long-lived shell process that executes blocks one after another.
+// Commands are sent over the shell's stdin (the control channel) and the shell
+// reports the exit code of every block back over file descriptor 3.
+// Because all blocks run inside the same shell process, variables, functions,
+// aliases and options set by one block are visible to the next one.
+type Session struct {
+	cmd *exec.Cmd
+	// pid is the process id of the shell, cmd may be a process that starts it
+	pid    int
+	stdin  io.WriteCloser
+	status *os.File
+	lines  *bufio.Scanner
+	mut    sync.Mutex
+	runs   map[int]*Run
+	// wd and env are the working directory and environment the session shell
+	// is known to have, changes made by builtins are sent before the next run.
+	wd   string
+	env  []string
+	busy bool
+	dead bool
+}
+
+// Run is a single block executed inside a Session.
+// Every run has its own PTY so the output of each block is a separate stream.
+type Run struct {
+	ID int
+	// Pid is the process id of the session shell executing the run.
+	Pid      int
+	PTY      *os.File
+	tty      *os.File
+	procs    *commands.Tracker
+	done     chan struct{}
+	exitCode int
+}
+
+var (
+	sessionMut = sync.Mutex{}
+	session    *Session
+)
+
+// RunInSession executes cmd inside the session shell, starting the session if
+// there is none yet or the previous one died.
+// It returns ErrSessionBusy if another block is still running in the session.
+func RunInSession(id int, cmd string) (*Run, error) {
+	sessionMut.Lock()
+	defer sessionMut.Unlock()
+
+	if session == nil || session.isDead() {
+		s, err := startSession()
+		if err != nil {
+			return nil, err
+		}
+		session = s
+	}
+
+	return session.run(id, cmd)
+}
+
+// SessionAlive reports whether the session shell is running and can run a
+// block right away. Only then do its variables matter for the next block.
+func SessionAlive() bool {
+	sessionMut.Lock()
+	defer sessionMut.Unlock()
+
+	if session == nil {
+		return false
+	}
+	session.mut.Lock()
+	defer session.mut.Unlock()
+	return !session.dead && !session.busy
+}
+
+// CloseSession stops the session shell if there is one.
+func CloseSession() {
+	sessionMut.Lock()
+	defer sessionMut.Unlock()
+
+	if session == nil {
+		return
+	}
+	_ = session.stdin.Close()
+	_, _ = commands.TerminateCommand(session.cmd)
+	session = nil
+}
+
+// Signal sends sig to the processes started by the run.
+// A shell with job control goes on with the next command once a job stops, so
+// the shell is stopped before the processes of the run and continued after
+// them.
+func (r *Run) Signal(sig commands.Signal) error {
+	switch sig {
+	case commands.Suspend:
+		if err := commands.SignalProcess(r.Pid, sig); err != nil {
+			return err
+		}
+		return r.procs.Signal(sig)
+	case commands.Resume:
+		if err := r.procs.Signal(sig); err != nil {
+			return err
+		}
+		return commands.SignalProcess(r.Pid, sig)
+	}
+	return r.procs.Signal(sig)
+}
+
+// Terminate stops every process started by the run and returns the ones that
+// had to be killed because they ignored SIGTERM. Background jobs of earlier
+// blocks keep running.
+// If the session shell itself is still busy afterwards (the block was a loop
+// in the shell), the session is stopped and the next block starts a new one.
+func (r *Run) Terminate() ([]int, error) {
+	survivors, err := r.procs.Terminate()
+	// the shell is stopped with a suspended run
+	_ = commands.SignalProcess(r.Pid, commands.Resume)
+
+	select {
+	case <-r.done:
+	case <-time.After(500 * time.Millisecond):
+		sessionMut.Lock()
+		current := session
+		sessionMut.Unlock()
+		if current != nil && current.pid == r.Pid {
+			CloseSession()
+		}
+	}
+
+	return survivors, err
+}
+
+func startSession() (*Session, error) {
+	sh, args, ok := sessionArgv()
+	if !ok {
+		return nil, ErrSessionUnsupported
+	}
+
+	statusR, statusW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(sh, args...)
+	cmd.Env = config.CopyEnviron()
+	cmd.ExtraFiles = []*os.File{statusW}
+	cmd.SysProcAttr = sessionSysProcAttr()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		_ = statusR.Close()
+		_ = statusW.Close()
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		_ = statusR.Close()
+		_ = statusW.Close()
+		return nil, err
+	}
+	// the shell holds its own copy now
+	_ = statusW.Close()
+
+	s := &Session{
+		cmd:    cmd,
+		stdin:  stdin,
+		status: statusR,
+		lines:  bufio.NewScanner(statusR),
+		runs:   make(map[int]*Run),
+		wd:     currentWd(),
+		env:    config.CopyEnviron(),
+	}
+
+	if _, err := io.WriteString(stdin, sessionPrologue()); err != nil {
+		s.kill()
+		return nil, err
+	}
+	// the first line is the process id of the shell
+	if !s.lines.Scan() {
+		s.kill()
+		return nil, errors.New("shell session did not start")
+	}
+	pid, ok := strings.CutPrefix(s.lines.Text(), "pid ")
+	if s.pid, err = strconv.Atoi(pid); !ok || err != nil {
+		s.kill()
+		return nil, fmt.Errorf("shell session reported %q instead of its pid", s.lines.Text())
+	}
+
+	go s.readStatus()
+
+	return s, nil
+}
+
+// kill stops a session that failed to start.
+func (s *Session) kill() {
+	_ = s.stdin.Close()
+	_, _ = commands.TerminateCommand(s.cmd)
+	_ = s.cmd.Wait()
+	_ = s.status.Close()
+}
+
+func (s *Session) isDead() bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.dead
+}
+
+func (s *Session) run(id int, cmd string) (*Run, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.busy {
+		return nil, ErrSessionBusy
+	}
+
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Run{
+		ID:       id,
+		Pid:      s.pid,
+		PTY:      ptmx,
+		tty:      tty,
+		procs:    commands.Track(s.pid),
+		done:     make(chan struct{}),
+		exitCode: -1,
+	}
+
+	line := s.sync() + sessionRun(id, tty.Name(), cmd)
+	// the shell has the environment of ohmygosh now
+	startCommand(id, s.env)
+	if _, err := io.WriteString(s.stdin, line); err != nil {
+		ForgetCommand(id)
+		_ = ptmx.Close()
+		_ = tty.Close()
+		return nil, err
+	}
+
+	s.runs[id] = r
+	s.busy = true
+
+	return r, nil
+}
+
+// readStatus reads the exit codes reported by the session shell.
+// Each line has the format "<block id> <exit code>".
+func (s *Session) readStatus() {
+	for s.lines.Scan() {
+		fields := strings.Fields(s.lines.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		exitCode, err := strconv.Atoi(fields[1])
+		if err != nil {
+			exitCode = -1
+		}
+
+		s.mut.Lock()
+		if r, ok := s.runs[id]; ok {
+			delete(s.runs, id)
+			r.finish(exitCode)
+		}
+		ForgetCommand(id)
+		// the shell reported its state over IPC before the exit code. Its
+		// changes to the environment are in Environ now, sending them again
+		// before the next run doesn't change the shell.
+		s.wd = currentWd()
+		s.busy = false
+		s.mut.Unlock()
+	}
+
+	// the shell is gone, finish everything that is still waiting for it
+	s.mut.Lock()
+	s.dead = true
+	for id, r := range s.runs {
+		delete(s.runs, id)
+		r.finish(-1)
+	}
+	s.mut.Unlock()
+	_ = s.status.Close()
+	_ = s.cmd.Wait()
+}
+
+// sync returns the commands that bring the session shell up to date with the
+// working directory and environment of ohmygosh.
+func (s *Session) sync() string {
+	var (
+		wd    string
+		set   [][2]string
+		unset []string
+	)
+	if dir := currentWd(); dir != s.wd {
+		wd = dir
+		s.wd = dir
+	}
+
+	env := config.CopyEnviron()
+	old := envMap(s.env)
+	current := envMap(env)
+	for key, value := range current {
+		if !IsName(key) {
+			continue
+		}
+		if oldValue, ok := old[key]; !ok || oldValue != value {
+			set = append(set, [2]string{key, value})
+		}
+	}
+	for key := range old {
+		if _, ok := current[key]; !ok && IsName(key) {
+			unset = append(unset, key)
+		}
+	}
+	s.env = env
+
+	if wd == "" && len(set) == 0 && len(unset) == 0 {
+		return ""
+	}
+	return sessionSync(wd, set, unset)
+}
+
+func envMap(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, e := range env {
+		if key, value, ok := strings.Cut(e, "="); ok {
+			m[key] = value
+		}
+	}
+	return m
+}
+
+// IsName reports whether key is a valid shell variable name.
+func IsName(key string) bool {
+	if key == "" || (key[0] >= '0' && key[0] <= '9') {
+		return false
+	}
+	for _, c := range key {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Run) finish(exitCode int) {
+	r.exitCode = exitCode
+	// Closing our end of the tty lets reads on the PTY return once the command
+	// and everything it spawned have closed theirs.
+	_ = r.tty.Close()
+	close(r.done)
+}
+
+// Done is closed once the session shell reported the exit code of the run.
+func (r *Run) Done() <-chan struct{} {
+	return r.done
+}
+
+// Wait blocks until the run is finished and returns its exit code.
+func (r *Run) Wait() int {
+	<-r.done
+	return r.exitCode
+}
+
+// Quote returns s as a single quoted POSIX shell word.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func sessionLine(format string, a ...any) string {
+	return fmt.Sprintf(format, a...) + "\n"
+}
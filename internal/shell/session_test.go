@@ -0,0 +1,90 @@
+//go:build !windows
+
+package shell_test
+
+import (
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+	"github.com/tsukinoko-kun/ohmygosh/internal/shell"
+)
+
+// run executes cmd in the session, writes input to its PTY and returns the
+// output once the run is finished. The PTY is left open.
+func run(t *testing.T, id int, cmd string, input string) (*shell.Run, string) {
+	t.Helper()
+	r, err := shell.RunInSession(id, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan string, 1)
+	go func() {
+		var sb strings.Builder
+		buf := make([]byte, 1024)
+		for {
+			n, err := r.PTY.Read(buf)
+			sb.Write(buf[:n])
+			if err != nil {
+				break
+			}
+		}
+		out <- sb.String()
+	}()
+	if input != "" {
+		if _, err := io.WriteString(r.PTY, input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-r.Done():
+	case <-time.After(5 * time.Second):
+		_, _ = r.Terminate()
+		t.Fatalf("%q didn't finish", cmd)
+	}
+	select {
+	case output := <-out:
+		return r, strings.ReplaceAll(output, "\r\n", "\n")
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the output of %q didn't end", cmd)
+		return nil, ""
+	}
+}
+
+func TestSessionTTY(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+	config.Get.Shell.Exe = bash
+	config.Get.Shell.Args = nil
+	defer shell.CloseSession()
+
+	// jobs of the first block read its tty like any other
+	first, output := run(t, 1, `FOO=bar ; read -r line ; head -n1 ; echo "$line $$"`, "a\nb\n")
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 || lines[len(lines)-2] != "b" || !strings.HasPrefix(lines[len(lines)-1], "a ") {
+		t.Fatalf("Expected the input of the block, got %q", output)
+	}
+	pid := strings.TrimPrefix(lines[len(lines)-1], "a ")
+
+	// the tty of the first block is not the terminal of the session
+	_, output = run(t, 2, `echo x >/dev/tty`, "")
+	if strings.Contains(output, "x\n") {
+		t.Errorf("Expected no /dev/tty, got %q", output)
+	}
+
+	// closing the PTY of a block doesn't hang up the session
+	if err := first.PTY.Close(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	_, output = run(t, 3, `echo "$FOO $$"`, "")
+	if expected := "bar " + pid + "\n"; output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
@@ -1,110 +1,152 @@
 package shell
 
 import (
-	"context"
 	"crypto/rand"
 	"errors"
 	"fmt"
-	"io"
 	"net"
-	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+	"github.com/tsukinoko-kun/ohmygosh/internal/data"
+	"github.com/tsukinoko-kun/ohmygosh/internal/ipc"
 	ui "github.com/tsukinoko-kun/ohmygosh/internal/ui/exit"
 )
 
 var (
-	Wd, _     = os.Getwd()
-	ipcMut    = sync.Mutex{}
-	ipcKey    = rand.Text()
-	ipcLn     net.Listener
-	ipcAddr   string
-	ipcServer *http.Server
+	Wd, _ = os.Getwd()
+	// OldWd is the previous working directory, used by `cd -`.
+	OldWd   string
+	ipcMut  = sync.Mutex{}
+	ipcKey  = rand.Text()
+	ipcLn   net.Listener
+	ipcPath string
+	ipcExe  string
+	// startEnv holds the environment the command of a block started with by
+	// block id, until the command reports its environment.
+	startEnv = map[int][]string{}
 )
 
-func ipcHandler(w http.ResponseWriter, r *http.Request) {
-	defer r.Body.Close()
-	// check for valid key
-	if r.Header.Get("X-Key") != ipcKey {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
+func ipcHandler(cmd string, arg string, payload []byte) error {
 	ipcMut.Lock()
 	defer ipcMut.Unlock()
 
-	buf, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	line := strings.TrimSpace(string(buf))
-	args := strings.SplitN(line, " ", 2)
-	switch len(args) {
-	case 2:
-		// all good
-	case 1:
-		args = append(args, "")
-	case 0:
-		http.Error(w, "No command", http.StatusBadRequest)
-		return
-	}
-	cmd := strings.TrimSpace(args[0])
-	arg := strings.TrimSpace(args[1])
 	switch cmd {
 	case "exit":
-		if arg == "" {
-			ui.Exit(0)
+		if i, err := strconv.Atoi(strings.TrimSpace(arg)); err == nil {
+			ui.Exit(i)
 		} else {
-			if i, err := strconv.Atoi(arg); err == nil {
-				ui.Exit(i)
-			} else {
-				ui.Exit(0)
-			}
+			ui.Exit(0)
 		}
 	case "cd":
-		if err := os.Chdir(arg); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+		if err := chdir(arg); err != nil {
+			return err
+		}
+	case "env":
+		// the environment is sent as NUL separated list, values may contain newlines
+		env := strings.Split(strings.TrimRight(string(payload), "\x00"), "\x00")
+		id, err := strconv.Atoi(arg)
+		start, ok := startEnv[id]
+		if err != nil || !ok {
+			// reported by hand, take it over as it is
+			start = config.CopyEnviron()
 		}
-		Wd = arg
+		delete(startEnv, id)
+		config.ApplyEnviron(start, env)
 	default:
-		http.Error(w, "Unknown command", http.StatusNotFound)
-		return
+		return errors.New("unknown command")
 	}
-	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// startCommand records env as the environment the command of the block id
+// starts with. Only the changes the command makes to it are taken over when
+// it reports its environment.
+func startCommand(id int, env []string) {
+	ipcMut.Lock()
+	defer ipcMut.Unlock()
+	startEnv[id] = env
+}
+
+// Command returns the command that runs cmd for the block id in a shell
+// process of its own. It reports the state of the shell when it is done.
+func Command(id int, cmd string) *exec.Cmd {
+	sh, args := GetShellArgv()
+	c := exec.Command(sh, append(args, Escape(Wrap(id, Aliases()+cmd)))...)
+	c.Env = config.CopyEnviron()
+	startCommand(id, c.Env)
+	return c
+}
+
+// ForgetCommand drops the start environment of the block id if its command
+// ended without reporting its environment.
+func ForgetCommand(id int) {
+	ipcMut.Lock()
+	defer ipcMut.Unlock()
+	delete(startEnv, id)
+}
+
+// Chdir changes the working directory of ohmygosh.
+// The session shell follows before it runs the next block.
+func Chdir(dir string) error {
+	ipcMut.Lock()
+	defer ipcMut.Unlock()
+	return chdir(dir)
+}
+
+// currentWd returns Wd, the IPC server changes it concurrently.
+func currentWd() string {
+	ipcMut.Lock()
+	defer ipcMut.Unlock()
+	return Wd
+}
+
+func chdir(dir string) error {
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	if dir != Wd {
+		OldWd = Wd
+	}
+	Wd = dir
+	return nil
 }
 
 func Init() {
-	http.HandleFunc("/ipc", ipcHandler)
-	ipcServer = &http.Server{Addr: ":0", Handler: http.DefaultServeMux}
 	var err error
-	ipcLn, err = net.Listen("tcp", ipcServer.Addr)
+	if ipcExe, err = os.Executable(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding executable: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(data.Path, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating data directory: %v\n", err)
+		os.Exit(1)
+	}
+	// the socket directory must not be accessible by other users
+	ipcPath = filepath.Join(data.Path, "ipc", fmt.Sprintf("ipc-%d.sock", os.Getpid()))
+	ipcLn, err = ipc.Listen(ipcPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error starting IPC server: %v\n", err)
 		os.Exit(1)
 	}
-	ipcAddr = fmt.Sprintf("http://localhost:%d", (ipcLn.Addr().(*net.TCPAddr)).Port)
-	if err := ipcServer.Serve(ipcLn); err != nil {
-		if errors.Is(err, http.ErrServerClosed) {
-			return
-		}
+	if err := ipc.Serve(ipcLn, ipcKey, ipcHandler); err != nil {
 		fmt.Fprintf(os.Stderr, "Error serving IPC server: %v\n", err)
 		os.Exit(1)
 	}
 }
 
 func ClearIPC() {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	_ = ipcServer.Shutdown(ctx)
-	_ = ipcLn.Close()
+	if ipcLn != nil {
+		_ = ipcLn.Close()
+	}
+	if ipcPath != "" {
+		_ = os.Remove(ipcPath)
+	}
 }
 
 func GetShellName() string {
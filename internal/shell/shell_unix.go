@@ -5,12 +5,27 @@ package shell
 
 import (
 	"fmt"
-	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 
 	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+	"github.com/tsukinoko-kun/ohmygosh/internal/ipc"
 )
 
+// sessionShells are the shells that understand the POSIX syntax used to drive
+// a Session.
+var sessionShells = []string{
+	"bash",
+	"zsh",
+	"sh",
+	"dash",
+	"ash",
+	"ksh",
+	"mksh",
+}
+
 func GetShellArgv() (string, []string) {
 	shell := config.Get.Shell.Exe
 	args := make([]string, len(config.Get.Shell.Args), len(config.Get.Shell.Args)+1)
@@ -19,32 +34,103 @@ func GetShellArgv() (string, []string) {
 	return shell, args
 }
 
+// ipcCommand returns a command that sends cmd to the IPC server using the
+// built-in client.
+func ipcCommand(cmd string) string {
+	return fmt.Sprintf(`%s=%s %s=%s %s ipc %s`, ipc.EnvSocket, Quote(ipcPath), ipc.EnvKey, Quote(ipcKey), Quote(ipcExe), cmd)
+}
+
 func Aliases() string {
 	aliases := make(map[string]string)
 	sb := strings.Builder{}
-	if _, err := exec.LookPath("curl"); err == nil {
-		aliases["exit"] = fmt.Sprintf(`curl -X POST -H "X-Key: %s" %s/ipc -d "exit $1" ; builtin exit $1`, ipcKey, ipcAddr)
-		aliases["close"] = fmt.Sprintf(`curl -X POST -H "X-Key: %s" %s/ipc -d "exit $1" ; builtin exit $1`, ipcKey, ipcAddr)
-	} else if _, err := exec.LookPath("wget"); err == nil {
-		aliases["exit"] = fmt.Sprintf(`wget --method=POST --header="X-Key: %s" --post-data="exit $1" %s/ipc -O - ; builtin exit $1`, ipcKey, ipcAddr)
-		aliases["close"] = fmt.Sprintf(`wget --method=POST --header="X-Key: %s" --post-data="exit $1" %s/ipc -O - ; builtin exit $1`, ipcKey, ipcAddr)
-	}
+	aliases["exit"] = ipcCommand(`exit "$1"`) + ` ; builtin exit $1`
+	aliases["close"] = ipcCommand(`exit "$1"`) + ` ; builtin exit $1`
 	for alias, cmd := range aliases {
-		sb.WriteString(fmt.Sprintf(`%s() { %s } ; `, alias, cmd))
+		sb.WriteString(fmt.Sprintf(`%s() { %s ; } ; `, alias, cmd))
 	}
 	return sb.String()
 }
 
-func Wrap(cmd string) string {
-	if _, err := exec.LookPath("curl"); err == nil {
-		return fmt.Sprintf(`%s ; ohmybashexitcode=$? ; curl -X POST -H "X-Key: %s" %s/ipc -d "cd $(pwd)" ; builtin exit $ohmybashexitcode`, cmd, ipcKey, ipcAddr)
-	} else if _, err := exec.LookPath("wget"); err == nil {
-		return fmt.Sprintf(`%s ; ohmybashexitcode=$? ; wget --method=POST --header="X-Key: %s" --post-data="cd $(pwd)" %s/ipc -O - ; builtin exit $ohmybashexitcode`, cmd, ipcKey, ipcAddr)
-	} else {
-		return cmd
-	}
+// reportState returns a command that reports the state of the shell (the
+// working directory and the exported environment) after the command of the
+// block id to the IPC server.
+func reportState(id int) string {
+	return ipcCommand(fmt.Sprintf("sync %d", id))
+}
+
+func Wrap(id int, cmd string) string {
+	return fmt.Sprintf(`%s ; ohmybashexitcode=$? ; %s ; builtin exit $ohmybashexitcode`, cmd, reportState(id))
 }
 
 func Escape(s string) string {
 	return s
 }
+
+// sessionArgv returns the command that starts the session shell. The shell
+// runs below a /bin/sh that leads its session. Only the leader of a session
+// acquires a controlling terminal by opening a tty, so the tty of a block
+// never becomes the controlling terminal of the session shell: closing it
+// doesn't hang up the shell, and jobs reading it are not stopped for reading
+// from the terminal in the background. Commands in the session have no
+// /dev/tty.
+func sessionArgv() (string, []string, bool) {
+	shell := config.Get.Shell.Exe
+	if !slices.Contains(sessionShells, filepath.Base(shell)) {
+		return "", nil, false
+	}
+	// the exit keeps /bin/sh from replacing itself with the shell
+	args := []string{"-c", `"$0" "$@" ; exit $?`, shell}
+	args = append(args, config.Get.Shell.Args...)
+	args = append(args, "-s")
+	return "/bin/sh", args, true
+}
+
+func sessionSysProcAttr() *syscall.SysProcAttr {
+	// Own session without a controlling terminal, signals from the terminal
+	// ohmygosh runs in must not reach the session shell.
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// sessionPrologue is sent to a new session shell once before the first run.
+// Not every shell allows redefining exit, that must not end the session.
+// Job control puts every job of a run in its own process group, so signals
+// for a block don't reach the background jobs of others. Shells that have no
+// job control without a terminal (dash) keep all processes in one group.
+// With job control a foreground job killed by SIGINT interrupts the shell too,
+// the trap keeps that from ending the session.
+// The shell reports its process id first, it is not the process ohmygosh
+// started.
+func sessionPrologue() string {
+	return sessionLine(`printf 'pid %%d\n' "$$" >&3`) +
+		sessionLine(`command eval 'trap : INT' >/dev/null 2>&1`) +
+		sessionLine(`command eval 'set -m' >/dev/null 2>&1`) +
+		sessionLine(`command eval %s >/dev/null 2>&1`, Quote(Aliases()))
+}
+
+// sessionRun returns the line that makes the session shell execute cmd with
+// tty as stdin, stdout and stderr and report the exit code over fd 3.
+// `command eval` keeps syntax errors from terminating the session shell.
+// The report is a line of its own, an interrupt only aborts the current line.
+func sessionRun(id int, tty string, cmd string) string {
+	return sessionLine(`command eval %s <%s >%s 2>&1 3>&-`, Quote(cmd), Quote(tty), Quote(tty)) +
+		sessionLine(
+			`ohmygoshstatus=$? ; %s >/dev/null 2>&1 ; printf '%%d %%d\n' %d "$ohmygoshstatus" >&3`,
+			reportState(id), id,
+		)
+}
+
+// sessionSync returns the line that changes the working directory of the
+// session shell to wd (if not empty) and exports or unsets variables.
+func sessionSync(wd string, set [][2]string, unset []string) string {
+	var sb strings.Builder
+	if wd != "" {
+		sb.WriteString(fmt.Sprintf(`cd -- %s ; `, Quote(wd)))
+	}
+	for _, kv := range set {
+		sb.WriteString(fmt.Sprintf(`export %s=%s ; `, kv[0], Quote(kv[1])))
+	}
+	for _, key := range unset {
+		sb.WriteString(fmt.Sprintf(`unset %s ; `, key))
+	}
+	return sessionLine(`command eval %s >/dev/null 2>&1`, Quote(sb.String()))
+}
@@ -7,9 +7,11 @@ import (
 	"encoding/base64"
 	"fmt"
 	"strings"
+	"syscall"
 	"unicode/utf16"
 
 	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+	"github.com/tsukinoko-kun/ohmygosh/internal/ipc"
 )
 
 func GetShellArgv() (string, []string) {
@@ -20,18 +22,29 @@ func GetShellArgv() (string, []string) {
 	return shell, args
 }
 
+// ipcCommand returns a command that sends cmd to the IPC server using the
+// built-in client.
+func ipcCommand(cmd string) string {
+	return fmt.Sprintf(`$env:%s = %s ; $env:%s = %s ; & %s ipc %s`, ipc.EnvSocket, psQuote(ipcPath), ipc.EnvKey, psQuote(ipcKey), psQuote(ipcExe), cmd)
+}
+
+// psQuote returns s as a single quoted PowerShell string.
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
 func Aliases() string {
 	aliases := make(map[string]string)
 	sb := strings.Builder{}
-	aliases["close"] = fmt.Sprintf(`Invoke-RestMethod -Uri "%s/ipc" -Method POST -Headers @{"X-Key" = %q} -Body "exit $($args[0])"`, ipcAddr, ipcKey)
+	aliases["close"] = ipcCommand(`exit "$($args[0])"`)
 	for alias, cmd := range aliases {
 		sb.WriteString(fmt.Sprintf(`function %s { %s } ; `, alias, cmd))
 	}
 	return sb.String()
 }
 
-func Wrap(cmd string) string {
-	return fmt.Sprintf(`try { %s } finally { Invoke-RestMethod -Uri "%s/ipc" -Method POST -Headers @{"X-Key" = %q} -Body "cd $(pwd)" }`, cmd, ipcAddr, ipcKey)
+func Wrap(id int, cmd string) string {
+	return fmt.Sprintf(`try { %s } finally { %s }`, cmd, ipcCommand(fmt.Sprintf("sync %d", id)))
 }
 
 // Escape provides an alternative approach using
@@ -49,3 +62,25 @@ func Escape(command string) string {
 
 	return base64.StdEncoding.EncodeToString(bytes)
 }
+
+// Sessions drive the shell with POSIX syntax, PowerShell falls back to one
+// process per command.
+func sessionArgv() (string, []string, bool) {
+	return "", nil, false
+}
+
+func sessionSysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+func sessionPrologue() string {
+	return ""
+}
+
+func sessionRun(int, string, string) string {
+	return ""
+}
+
+func sessionSync(string, [][2]string, []string) string {
+	return ""
+}
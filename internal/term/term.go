@@ -12,6 +12,7 @@ import (
 var (
 	hostname string
 	Cols     uint16
+	Rows     uint16
 )
 
 func init() {
@@ -25,6 +26,11 @@ func init() {
 	} else {
 		Cols = uint16(80)
 	}
+	if rows, err := strconv.Atoi(os.Getenv("LINES")); err == nil && rows > 0 {
+		Rows = uint16(rows)
+	} else {
+		Rows = uint16(24)
+	}
 }
 
 // SetSessionTitle sets the terminal session title using an OSC escape sequence.
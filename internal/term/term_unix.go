@@ -12,6 +12,7 @@ import (
 	"github.com/creack/pty"
 	"github.com/tsukinoko-kun/ohmygosh/internal/config"
 	"github.com/tsukinoko-kun/ohmygosh/internal/ui/exit"
+	"golang.org/x/sys/unix"
 )
 
 func InheritSize() {
@@ -21,6 +22,7 @@ func InheritSize() {
 		for range c {
 			if size, err := pty.GetsizeFull(os.Stdin); err == nil {
 				Cols = size.Cols - 2 // padding
+				Rows = size.Rows
 				config.SetEnviron("COLUMNS", strconv.Itoa(int(Cols)))
 				config.SetEnviron("LINES", strconv.Itoa(int(size.Rows)))
 				_ = exit.InheritSize(size)
@@ -30,3 +32,19 @@ func InheritSize() {
 	// send one now to set initial size
 	c <- syscall.SIGWINCH
 }
+
+// Signals reports whether the line discipline of the PTY turns ctrl+c, ctrl+z
+// and ctrl+\ into signals (ISIG). Programs in raw mode read them as input.
+func Signals(ptmx *os.File) bool {
+	conn, err := ptmx.SyscallConn()
+	if err != nil {
+		return true
+	}
+	isig := true
+	_ = conn.Control(func(fd uintptr) {
+		if termios, err := unix.IoctlGetTermios(int(fd), ioctlGetTermios); err == nil {
+			isig = termios.Lflag&unix.ISIG != 0
+		}
+	})
+	return isig
+}
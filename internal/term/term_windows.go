@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package term
+
+import "os"
+
+// Signals always returns false, there is no line discipline on Windows.
+func Signals(*os.File) bool {
+	return false
+}
@@ -0,0 +1,8 @@
+//go:build darwin
+// +build darwin
+
+package term
+
+import "golang.org/x/sys/unix"
+
+const ioctlGetTermios = unix.TIOCGETA
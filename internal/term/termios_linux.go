@@ -0,0 +1,8 @@
+//go:build !windows && !darwin
+// +build !windows,!darwin
+
+package term
+
+import "golang.org/x/sys/unix"
+
+const ioctlGetTermios = unix.TCGETS
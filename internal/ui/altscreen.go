@@ -0,0 +1,145 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	xterm "github.com/charmbracelet/x/term"
+	"github.com/creack/pty"
+	"github.com/muesli/cancelreader"
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+)
+
+// altScreenPolicy returns what happens when command switches to the alternate
+// screen, commands are looked up by the name of the program.
+func altScreenPolicy(command string) string {
+	if words := strings.Fields(command); len(words) > 0 {
+		if policy, ok := config.Get.Ui.AltScreenCommands[filepath.Base(words[0])]; ok {
+			return policy
+		}
+	}
+	return config.Get.Ui.AltScreen
+}
+
+// syncAltScreen applies the alt screen policy of the block when its program
+// switches to the alternate screen or leaves it.
+func (m *Model) syncAltScreen(block *CommandBlock) tea.Cmd {
+	if block.InDirectMode || block.Passthrough {
+		return nil
+	}
+	block.mu.Lock()
+	alt := block.IsRunning && block.compile().AltScreen()
+	block.mu.Unlock()
+	if alt {
+		block.UsesAltScreen = true
+	}
+
+	switch altScreenPolicy(block.Command) {
+	case config.AltScreenEmbed:
+		m.setEmbedded(block, alt)
+	case config.AltScreenPassthrough:
+		if alt {
+			block.Passthrough = true
+			return tea.Exec(&passthrough{block: block}, func(err error) tea.Msg {
+				return PassthroughFinishedMsg{ID: block.ID, Err: err}
+			})
+		}
+	case config.AltScreenRestart:
+		if alt {
+			return restartFullScreen(block)
+		}
+	}
+	return nil
+}
+
+// restartFullScreen kills the command of the block in the background and
+// runs it again with the terminal once it is gone. Commands with side effects
+// run twice.
+func restartFullScreen(block *CommandBlock) tea.Cmd {
+	// Clean up PTY
+	if block.PTY != nil {
+		_ = block.PTY.Close()
+	}
+
+	block.InDirectMode = true
+	block.mu.Lock()
+	block.resetOutput()
+	block.Output.WriteString("[Restarting in full-screen mode...]\n")
+	block.mu.Unlock()
+
+	// Execute in direct mode
+	return tea.Sequence(terminateBlock(block), executeInDirectMode(block.ID, block.Command))
+}
+
+// passthrough hands the terminal to the program of a block until it leaves
+// the alternate screen or finishes, without restarting it. It is run with
+// tea.Exec, the output still goes to the screen of the block.
+type passthrough struct {
+	block  *CommandBlock
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func (p *passthrough) SetStdin(r io.Reader)  { p.stdin = r }
+func (p *passthrough) SetStdout(w io.Writer) { p.stdout = w }
+func (p *passthrough) SetStderr(io.Writer)   {}
+
+func (p *passthrough) Run() error {
+	block := p.block
+	if f, ok := p.stdin.(*os.File); ok {
+		state, err := xterm.MakeRaw(f.Fd())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = xterm.Restore(f.Fd(), state) }()
+
+		// The program draws itself again for the size of the terminal
+		if size, err := pty.GetsizeFull(f); err == nil {
+			block.mu.Lock()
+			block.compile().Resize(int(size.Cols), int(size.Rows))
+			block.mu.Unlock()
+			_ = pty.Setsize(block.PTY, size)
+			block.rec.resize(int(size.Cols), int(size.Rows))
+		}
+	}
+
+	input, err := cancelreader.NewReader(p.stdin)
+	if err != nil {
+		return err
+	}
+	defer input.Cancel()
+	go func() { _, _ = io.Copy(block.PTY, input) }()
+
+	// Draw what the program drew so far, from now on it only sends changes
+	block.mu.Lock()
+	screen := block.compile()
+	row, col, visible := screen.Cursor()
+	draw := fmt.Sprintf("\x1b[?1049h\x1b[H\x1b[2J%s\x1b[%d;%dH", strings.Join(screen.Lines(), "\r\n"), row+1, col+1)
+	block.mu.Unlock()
+	if !visible {
+		draw += "\x1b[?25l"
+	}
+	if _, err := io.WriteString(p.stdout, draw); err != nil {
+		return err
+	}
+
+	alt := true
+	for alt && block.ring.wait() {
+		block.mu.Lock()
+		output := block.drain()
+		alt = block.compile().AltScreen()
+		block.mu.Unlock()
+		if _, err := io.WriteString(p.stdout, output); err != nil {
+			return err
+		}
+	}
+	if alt {
+		// The program finished without leaving the alternate screen
+		_, _ = io.WriteString(p.stdout, "\x1b[?1049l")
+	}
+	return nil
+}
@@ -1,9 +1,11 @@
 package ansicompiler
 
 import (
-	"regexp"
-	"strconv"
+	"io"
+	"net/url"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/tsukinoko-kun/ohmygosh/internal/term"
 )
@@ -13,7 +15,7 @@ type Token interface {
 	IsToken()
 }
 
-// RuneToken represents a single character
+// RuneToken represents a single character or C0 control character
 type RuneToken struct {
 	Rune rune
 }
@@ -22,15 +24,36 @@ func (RuneToken) IsToken() {}
 
 // AnsiToken represents an ANSI escape sequence
 type AnsiToken struct {
+	// Sequence is the complete sequence in its 7-bit form
 	Sequence string
+	Kind     SequenceKind
+	// Private is the private marker of CSI and DCS sequences ('?', '>', '<',
+	// '=') or 0
+	Private       byte
+	Intermediates string
+	Final         rune
+	// Params are the parameters of CSI and DCS sequences, each with its colon
+	// separated sub-parameters
+	Params [][]int
+	// Data is the string of OSC, DCS, SOS, PM and APC sequences
+	Data string
 }
 
 func (AnsiToken) IsToken() {}
 
 // Cell represents a character in the buffer with its styling
 type Cell struct {
-	Rune  rune
-	Style string
+	Rune rune
+	// Combining are the zero width runes drawn together with Rune, like
+	// combining marks, variation selectors and joined emoji
+	Combining string
+	// Wide is set for characters that take two columns, the cell right of
+	// them is a Continuation that isn't drawn
+	Wide         bool
+	Continuation bool
+	Style        Style
+	// Link is the URI of an OSC 8 hyperlink
+	Link string
 }
 
 // Buffer represents a 2D grid of cells with cursor tracking
@@ -40,375 +63,684 @@ type Buffer struct {
 	cursorCol      int
 	savedCursorRow int
 	savedCursorCol int
-	currentStyle   string
-	softWrap       int
+	currentStyle   Style
+	currentLink    string
+	// width and height are the size of the screen, the screen starts at row
+	// top of cells, everything above is scrollback
+	width  int
+	height int
+	top    int
+	// autowrap is DECAWM, wrapNext is set after a character was written to
+	// the last column, the next one goes to the next line
+	autowrap bool
+	wrapNext bool
+	tabStops []bool
+	// cursorHidden is set by DECTCEM
+	cursorHidden bool
+	// primary is the main screen while the alternate screen is shown
+	primary *primaryScreen
+	// scrollTop and scrollBottom are the scroll region, as screen rows
+	scrollTop    int
+	scrollBottom int
+	// charsets are the character sets designated to G0 to G3, shift selects
+	// the one in use
+	charsets [4]rune
+	shift    int
+	last     lastCell
+	// parser and pending keep incomplete sequences and characters between
+	// writes
+	parser  *Parser
+	pending string
+	// lines caches the rendered rows, the first valid of them are up to date
+	lines []string
+	valid int
+	dirty bool
+	// scrollback limits the rows above the screen, older rows are rendered
+	// to spill
+	scrollback int
+	spill      io.Writer
+	truncated  int
 }
 
 // NewBuffer creates a new buffer
 func NewBuffer() *Buffer {
+	width := max(1, int(term.Cols))
+	height := max(1, int(term.Rows))
 	return &Buffer{
 		cells:        make([][]Cell, 0),
 		cursorRow:    0,
 		cursorCol:    0,
-		currentStyle: "",
-		softWrap:     int(term.Cols),
+		width:        width,
+		height:       height,
+		scrollBottom: height - 1,
+		autowrap:     true,
+		tabStops:     defaultTabStops(width),
+		parser:       NewParser(),
 	}
 }
 
 // ensureSize ensures the buffer is large enough for the given position
 func (b *Buffer) ensureSize(row, col int) {
 	// Expand rows if needed
-	for len(b.cells) <= row {
-		b.cells = append(b.cells, make([]Cell, 0))
-	}
+	b.ensureRow(row)
 
 	// Expand columns if needed
 	for len(b.cells[row]) <= col {
-		b.cells[row] = append(b.cells[row], Cell{Rune: ' ', Style: ""})
+		b.cells[row] = append(b.cells[row], Cell{Rune: ' '})
 	}
 }
 
-// setCursor sets the cursor position
+// setCursor sets the cursor position, row is a row of cells that is kept on
+// the screen
 func (b *Buffer) setCursor(row, col int) {
-	if row < 0 {
-		row = 0
+	if row < b.top {
+		row = b.top
 	}
-	if col < 0 {
-		col = 0
+	if row >= b.top+b.height {
+		row = b.top + b.height - 1
 	}
+	col = min(max(col, 0), b.width-1)
 	b.cursorRow = row
 	b.cursorCol = col
+	b.wrapNext = false
 }
 
 // writeRune writes a rune at the current cursor position and advances
 func (b *Buffer) writeRune(r rune) {
-	b.ensureSize(b.cursorRow, b.cursorCol)
-	b.cells[b.cursorRow][b.cursorCol] = Cell{
-		Rune:  r,
-		Style: b.currentStyle,
+	if b.charsets[b.shift] == '0' {
+		r = decSpecialGraphics(r)
+	}
+	if b.joinCluster(r) {
+		return
+	}
+	if b.wrapNext && b.autowrap {
+		b.lineFeed()
+		b.cursorCol = 0
 	}
-	b.cursorCol++
+	b.wrapNext = false
+
+	width := runeWidth(r)
+	if width == 0 {
+		b.combine(r)
+		return
+	}
+	b.putCell(r, min(width, 2))
 }
 
-// Tokenize splits the input string into tokens
+// Tokenize splits the input string into tokens.
+// An incomplete sequence at the end of input is dropped.
 func Tokenize(input string) []Token {
 	var tokens []Token
-	i := 0
-	runes := []rune(input)
-
-	for i < len(runes) {
-		if runes[i] == '\x1b' && i+1 < len(runes) && runes[i+1] == '[' {
-			// Found start of ANSI sequence
-			start := i
-			i += 2 // Skip \x1b[
-
-			// Find the end of the sequence (first letter after parameters)
-			for i < len(runes) && !isAnsiTerminator(runes[i]) {
-				i++
-			}
-			if i < len(runes) {
-				i++ // Include the terminator
-			}
+	NewParser().Parse(input, func(t Token) {
+		tokens = append(tokens, t)
+	})
+	return tokens
+}
+
+// CompileAnsi processes tokens and returns the final rendered string
+func CompileAnsi(input string) string {
+	return Compile(input).Render()
+}
+
+// Compile processes tokens and returns the buffer they leave
+func Compile(input string) *Buffer {
+	buffer := NewBuffer()
+	_, _ = buffer.WriteString(input)
+	return buffer
+}
 
-			sequence := string(runes[start:i])
-			tokens = append(tokens, AnsiToken{Sequence: sequence})
+// Write feeds output of a program to the buffer. Sequences and UTF-8 encoded
+// characters may be split across calls.
+func (b *Buffer) Write(p []byte) (int, error) {
+	return b.WriteString(string(p))
+}
+
+// WriteString is like Write for strings
+func (b *Buffer) WriteString(s string) (int, error) {
+	n := len(s)
+	if b.pending != "" {
+		s = b.pending + s
+		b.pending = ""
+	}
+	// an incomplete character at the end waits for the rest of it
+	if i := incompleteSuffix(s); i < len(s) {
+		b.pending = s[i:]
+		s = s[:i]
+	}
+	if s == "" {
+		return n, nil
+	}
+
+	// rows above the screen only change when the scrollback is erased
+	b.valid = min(b.valid, b.top)
+	b.dirty = true
+	b.parser.Parse(s, b.handle)
+	b.trimScrollback()
+	return n, nil
+}
+
+// handle processes a single token
+func (b *Buffer) handle(token Token) {
+	switch t := token.(type) {
+	case RuneToken:
+		if t.Rune < 0x20 {
+			executeControl(b, t.Rune)
 		} else {
-			// Regular character
-			tokens = append(tokens, RuneToken{Rune: runes[i]})
-			i++
+			b.writeRune(t.Rune)
+		}
+	case AnsiToken:
+		switch t.Kind {
+		case KindCSI:
+			processAnsiSequence(b, t)
+		case KindESC:
+			processEscapeSequence(b, t)
+		case KindOSC:
+			processOperatingSystemCommand(b, t)
 		}
 	}
+}
 
-	return tokens
+// incompleteSuffix returns the index of an incomplete UTF-8 encoded character
+// at the end of s or len(s)
+func incompleteSuffix(s string) int {
+	for i := len(s) - 1; i >= 0 && i > len(s)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(s[i]) {
+			if utf8.FullRuneInString(s[i:]) {
+				return len(s)
+			}
+			return i
+		}
+	}
+	return len(s)
 }
 
-// isAnsiTerminator checks if a character terminates an ANSI sequence
-func isAnsiTerminator(r rune) bool {
-	return (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')
+// Render converts the buffer to a string
+func (b *Buffer) Render() string {
+	return strings.Join(b.Lines(), "\n")
 }
 
-// CompileAnsi processes tokens and returns the final rendered string
-func CompileAnsi(input string) string {
-	tokens := Tokenize(input)
-	buffer := NewBuffer()
+// Lines renders the rows of the buffer. Rendered rows are cached, only rows
+// that changed since the last call are rendered again. The returned slice
+// must not be modified.
+func (b *Buffer) Lines() []string {
+	if !b.dirty {
+		return b.lines
+	}
 
-	for _, token := range tokens {
-		switch t := token.(type) {
-		case RuneToken:
-			switch t.Rune {
-			case '\n':
-				buffer.cursorRow++
-				buffer.cursorCol = 0
-			case '\r':
-				buffer.cursorCol = 0
-			case '\b':
-				buffer.writeRune(' ')
-				buffer.cursorCol = max(0, buffer.cursorCol-1)
-			default:
-				buffer.writeRune(t.Rune)
-			}
-		case AnsiToken:
-			processAnsiSequence(buffer, t.Sequence)
+	rows := b.Rows()
+	if b.primary != nil {
+		// the alternate screen always renders all of its rows
+		b.ensureRow(rows - 1)
+	}
+	b.valid = min(b.valid, rows, len(b.lines))
+	b.lines = b.lines[:b.valid]
+	for _, row := range b.cells[b.valid:rows] {
+		b.lines = append(b.lines, renderRow(row))
+	}
+	b.valid = min(rows, b.top)
+	b.dirty = false
+	return b.lines
+}
+
+// ScreenLines renders the rows of the screen without the scrollback above it
+func (b *Buffer) ScreenLines() []string {
+	lines := b.Lines()
+	return lines[min(b.top, len(lines)):]
+}
+
+// Rows returns the number of rows of the rendered output, trailing empty
+// rows like those left by deleted lines are not rendered. The alternate
+// screen always has the height of the screen.
+func (b *Buffer) Rows() int {
+	if b.primary != nil {
+		return b.height
+	}
+	rows := len(b.cells)
+	for rows > 0 && isEmptyRow(b.cells[rows-1]) {
+		rows--
+	}
+	return rows
+}
+
+// Cursor returns the position of the cursor on the screen and whether it is
+// shown
+func (b *Buffer) Cursor() (row, col int, visible bool) {
+	return b.screenRow(), b.cursorCol, !b.cursorHidden
+}
+
+// CursorLine renders the row of the cursor with the cursor drawn as a cell in
+// reverse video. ok is false if the cursor is hidden.
+func (b *Buffer) CursorLine() (row int, line string, ok bool) {
+	if b.cursorHidden {
+		return 0, "", false
+	}
+	var cells []Cell
+	if b.cursorRow < len(b.cells) {
+		cells = slices.Clone(b.cells[b.cursorRow])
+	}
+	for len(cells) <= b.cursorCol {
+		cells = append(cells, Cell{Rune: ' '})
+	}
+	cells[b.cursorCol].Style.Attrs ^= AttrReverse
+	return b.cursorRow, renderRow(cells), true
+}
+
+// Cells returns the cells of a row of the rendered output. The returned slice
+// must not be modified.
+func (b *Buffer) Cells(row int) []Cell {
+	if row < 0 || row >= len(b.cells) {
+		return nil
+	}
+	return b.cells[row]
+}
+
+// LinkAt returns the link of the cell at row and col of the rendered output
+func (b *Buffer) LinkAt(row, col int) string {
+	if row < 0 || row >= len(b.cells) || col < 0 || col >= len(b.cells[row]) {
+		return ""
+	}
+	return b.cells[row][col].Link
+}
+
+// HasLinks reports whether a row of the rendered output contains a link
+func (b *Buffer) HasLinks(row int) bool {
+	if row < 0 || row >= len(b.cells) {
+		return false
+	}
+	for _, cell := range b.cells[row] {
+		if cell.Link != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// SafeLink reports whether link is an http, https or mailto URL. Programs
+// choose their links, others can run code or read local files when they are
+// opened. A safe link starts with its scheme, an opener can't mistake it for
+// an option.
+func SafeLink(link string) bool {
+	if strings.HasPrefix(link, "-") {
+		return false
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return u.Opaque != ""
+	default:
+		return false
+	}
+}
+
+// executeControl handles C0 control characters
+func executeControl(buffer *Buffer, r rune) {
+	buffer.wrapNext = false
+	switch r {
+	case '\n', '\v', '\f':
+		buffer.lineFeed()
+		buffer.cursorCol = 0
+	case '\r':
+		buffer.cursorCol = 0
+	case '\b':
+		buffer.cursorCol = max(0, buffer.cursorCol-1)
+	case '\t':
+		buffer.cursorCol = buffer.nextTabStop(buffer.cursorCol, 1)
+	case 0x0e: // SO, shift to G1
+		buffer.shift = 1
+	case 0x0f: // SI, shift to G0
+		buffer.shift = 0
+	}
+}
+
+// processEscapeSequence handles escape sequences that are not CSI
+func processEscapeSequence(buffer *Buffer, t AnsiToken) {
+	buffer.wrapNext = false
+	if t.Intermediates != "" {
+		// Designate character set
+		switch t.Intermediates {
+		case "(":
+			buffer.charsets[0] = t.Final
+		case ")":
+			buffer.charsets[1] = t.Final
+		case "*":
+			buffer.charsets[2] = t.Final
+		case "+":
+			buffer.charsets[3] = t.Final
 		}
+		return
 	}
 
-	return renderBuffer(buffer)
+	switch t.Final {
+	case '7': // Save cursor (DECSC)
+		buffer.saveCursor()
+	case '8': // Restore cursor (DECRC)
+		buffer.restoreCursor()
+	case 'D': // Index
+		buffer.lineFeed()
+	case 'E': // Next line
+		buffer.lineFeed()
+		buffer.cursorCol = 0
+	case 'M': // Reverse index
+		buffer.reverseIndex()
+	case 'H': // Set tab stop (HTS)
+		buffer.tabStops[buffer.cursorCol] = true
+	case 'c': // Full reset
+		reset := NewBuffer()
+		reset.parser = buffer.parser
+		reset.SetScrollback(buffer.scrollback, buffer.spill)
+		reset.truncated = buffer.truncated
+		reset.dirty = true
+		*buffer = *reset
+	}
 }
 
-// processAnsiSequence handles ANSI escape sequences
-func processAnsiSequence(buffer *Buffer, sequence string) {
-	if !strings.HasPrefix(sequence, "\x1b[") {
+// processOperatingSystemCommand handles OSC sequences
+func processOperatingSystemCommand(buffer *Buffer, t AnsiToken) {
+	// OSC 8 ; params ; URI starts a hyperlink, an empty URI ends it
+	rest, ok := strings.CutPrefix(t.Data, "8;")
+	if !ok {
 		return
 	}
+	if _, uri, ok := strings.Cut(rest, ";"); ok {
+		buffer.currentLink = uri
+	}
+}
 
-	// Remove \x1b[ prefix
-	content := sequence[2:]
-	if len(content) == 0 {
+// processAnsiSequence handles CSI sequences
+func processAnsiSequence(buffer *Buffer, t AnsiToken) {
+	if t.Private == '?' && t.Intermediates == "" && (t.Final == 'h' || t.Final == 'l') {
+		handlePrivateMode(buffer, t)
+		return
+	}
+	if t.Private != 0 || t.Intermediates != "" {
+		// Other private sequences and cursor styles don't change the content
 		return
 	}
 
-	// Get the command (last character)
-	cmd := content[len(content)-1]
-	params := content[:len(content)-1]
+	if t.Final != 'm' {
+		buffer.wrapNext = false
+	}
 
-	switch cmd {
+	switch t.Final {
 	case 'A': // Cursor up
-		handleCursorUp(buffer, params)
+		handleCursorUp(buffer, t)
 	case 'B': // Cursor down
-		handleCursorDown(buffer, params)
+		handleCursorDown(buffer, t)
 	case 'C': // Cursor forward
-		handleCursorForward(buffer, params)
+		handleCursorForward(buffer, t)
 	case 'D': // Cursor backward
-		handleCursorBackward(buffer, params)
+		handleCursorBackward(buffer, t)
 	case 'E': // Cursor next line
-		handleCursorNextLine(buffer, params)
+		handleCursorNextLine(buffer, t)
 	case 'F': // Cursor previous line
-		handleCursorPreviousLine(buffer, params)
+		handleCursorPreviousLine(buffer, t)
 	case 'G': // Cursor horizontal absolute
-		handleCursorHorizontalAbsolute(buffer, params)
+		handleCursorHorizontalAbsolute(buffer, t)
 	case 'H', 'f': // Cursor position
-		handleCursorPosition(buffer, params)
+		handleCursorPosition(buffer, t)
+	case 'd': // Line position absolute
+		buffer.setCursor(buffer.top+count(t)-1, buffer.cursorCol)
 	case 's': // Save cursor position
-		buffer.savedCursorRow = buffer.cursorRow
-		buffer.savedCursorCol = buffer.cursorCol
+		buffer.saveCursor()
 	case 'u': // Restore cursor position
-		buffer.setCursor(buffer.savedCursorRow, buffer.savedCursorCol)
+		buffer.restoreCursor()
 	case 'J': // Erase in display
-		handleEraseInDisplay(buffer, params)
+		handleEraseInDisplay(buffer, t)
 	case 'K': // Erase in line
-		handleEraseInLine(buffer, params)
+		handleEraseInLine(buffer, t)
+	case 'r': // Set scroll region (DECSTBM)
+		buffer.setScrollRegion(count(t)-1, t.Param(1, 0)-1)
+	case 'L': // Insert lines
+		buffer.insertLines(count(t))
+	case 'M': // Delete lines
+		buffer.deleteLines(count(t))
+	case '@': // Insert characters
+		buffer.insertChars(count(t))
+	case 'P': // Delete characters
+		buffer.deleteChars(count(t))
+	case 'X': // Erase characters
+		buffer.eraseChars(count(t))
+	case 'S': // Scroll up
+		buffer.scrollUp(count(t))
+	case 'T': // Scroll down
+		buffer.scrollDown(count(t))
+	case 'I': // Cursor forward tabulation
+		buffer.cursorCol = buffer.nextTabStop(buffer.cursorCol, count(t))
+	case 'Z': // Cursor backward tabulation
+		buffer.cursorCol = buffer.nextTabStop(buffer.cursorCol, -count(t))
+	case 'g': // Tab clear
+		handleTabClear(buffer, t)
 	case 'm': // SGR (styling)
-		handleStyling(buffer, sequence)
-	case 'n': // DSR (Device Status Report)
-		break
-	case 'l': // DEC Private Mode Reset
-		break
-	case 'h': // DEC Private Mode Set
-		break
-	default:
-		// For unknown sequences, check if they might be styling
-		if isStylingSequence(sequence) {
-			handleStyling(buffer, sequence)
-		}
+		handleStyling(buffer, t)
 	}
 }
 
-// handleCursorPosition processes cursor positioning commands
-func handleCursorPosition(buffer *Buffer, params string) {
-	if params == "" {
-		buffer.setCursor(0, 0)
-		return
-	}
-
-	parts := strings.Split(params, ";")
-	row := 0
-	col := 0
-
-	if len(parts) >= 1 {
-		if r, err := strconv.Atoi(parts[0]); err == nil && r > 0 {
-			row = r - 1 // Convert to 0-based
+// handlePrivateMode sets or resets DEC private modes, only the ones that
+// change the content or the cursor are handled
+func handlePrivateMode(buffer *Buffer, t AnsiToken) {
+	set := t.Final == 'h'
+	for i := range t.Params {
+		switch t.Param(i, 0) {
+		case 7: // Autowrap (DECAWM)
+			buffer.autowrap = set
+			buffer.wrapNext = false
+		case 25: // Show cursor (DECTCEM)
+			buffer.cursorHidden = !set
+		case 47, 1047: // Alternate screen
+			if set {
+				buffer.enterAltScreen()
+			} else {
+				buffer.leaveAltScreen()
+			}
+		case 1049: // Alternate screen, saving the cursor
+			if set {
+				buffer.saveCursor()
+				buffer.enterAltScreen()
+			} else {
+				buffer.leaveAltScreen()
+				buffer.restoreCursor()
+			}
 		}
 	}
-	if len(parts) >= 2 {
-		if c, err := strconv.Atoi(parts[1]); err == nil && c > 0 {
-			col = c - 1 // Convert to 0-based
+}
+
+// handleTabClear clears the tab stop at the cursor or all of them
+func handleTabClear(buffer *Buffer, t AnsiToken) {
+	switch t.Param(0, 0) {
+	case 0:
+		buffer.tabStops[buffer.cursorCol] = false
+	case 3:
+		for i := range buffer.tabStops {
+			buffer.tabStops[i] = false
 		}
 	}
+}
 
-	buffer.setCursor(row, col)
+// count returns the first parameter of a sequence that moves the cursor,
+// missing and zero mean one.
+func count(t AnsiToken) int {
+	return max(1, t.Param(0, 1))
+}
+
+// handleCursorPosition processes cursor positioning commands
+func handleCursorPosition(buffer *Buffer, t AnsiToken) {
+	// Convert to 0-based
+	row := max(1, t.Param(0, 1)) - 1
+	col := max(1, t.Param(1, 1)) - 1
+	buffer.setCursor(buffer.top+row, col)
 }
 
 // handleCursorUp moves cursor up
-func handleCursorUp(buffer *Buffer, params string) {
-	n := 1
-	if params != "" {
-		if parsed, err := strconv.Atoi(params); err == nil {
-			n = parsed
-		}
-	}
-	buffer.setCursor(buffer.cursorRow-n, buffer.cursorCol)
+func handleCursorUp(buffer *Buffer, t AnsiToken) {
+	buffer.moveRow(-count(t))
 }
 
 // handleCursorDown moves cursor down
-func handleCursorDown(buffer *Buffer, params string) {
-	n := 1
-	if params != "" {
-		if parsed, err := strconv.Atoi(params); err == nil {
-			n = parsed
-		}
-	}
-	buffer.setCursor(buffer.cursorRow+n, buffer.cursorCol)
+func handleCursorDown(buffer *Buffer, t AnsiToken) {
+	buffer.moveRow(count(t))
 }
 
 // handleCursorForward moves cursor forward
-func handleCursorForward(buffer *Buffer, params string) {
-	n := 1
-	if params != "" {
-		if parsed, err := strconv.Atoi(params); err == nil {
-			n = parsed
-		}
-	}
-	buffer.setCursor(buffer.cursorRow, buffer.cursorCol+n)
+func handleCursorForward(buffer *Buffer, t AnsiToken) {
+	buffer.setCursor(buffer.cursorRow, buffer.cursorCol+count(t))
 }
 
 // handleCursorBackward moves cursor backward
-func handleCursorBackward(buffer *Buffer, params string) {
-	n := 1
-	if params != "" {
-		if parsed, err := strconv.Atoi(params); err == nil {
-			n = parsed
-		}
-	}
-	buffer.setCursor(buffer.cursorRow, buffer.cursorCol-n)
+func handleCursorBackward(buffer *Buffer, t AnsiToken) {
+	buffer.setCursor(buffer.cursorRow, buffer.cursorCol-count(t))
 }
 
-// handleCursorNextLine moves cursor down
-func handleCursorNextLine(buffer *Buffer, params string) {
-	n := 1
-	if params != "" {
-		if parsed, err := strconv.Atoi(params); err == nil {
-			n = parsed
-		}
-	}
-	buffer.setCursor(buffer.cursorRow+n, buffer.cursorCol)
+// handleCursorNextLine moves cursor to the start of a following line
+func handleCursorNextLine(buffer *Buffer, t AnsiToken) {
+	buffer.moveRow(count(t))
+	buffer.cursorCol = 0
 }
 
-// handleCursorPreviousLine moves cursor up
-func handleCursorPreviousLine(buffer *Buffer, params string) {
-	n := 1
-	if params != "" {
-		if parsed, err := strconv.Atoi(params); err == nil {
-			n = parsed
-		}
-	}
-	buffer.setCursor(buffer.cursorRow-n, buffer.cursorCol)
+// handleCursorPreviousLine moves cursor to the start of a previous line
+func handleCursorPreviousLine(buffer *Buffer, t AnsiToken) {
+	buffer.moveRow(-count(t))
+	buffer.cursorCol = 0
 }
 
 // handleCursorHorizontalAbsolute moves cursor to the specified column
-func handleCursorHorizontalAbsolute(buffer *Buffer, params string) {
-	n := 1
-	if params != "" {
-		if parsed, err := strconv.Atoi(params); err == nil {
-			n = parsed
-		}
-	}
-	buffer.setCursor(buffer.cursorRow, n-1)
+func handleCursorHorizontalAbsolute(buffer *Buffer, t AnsiToken) {
+	buffer.setCursor(buffer.cursorRow, count(t)-1)
 }
 
 // handleEraseInDisplay erases the display
-func handleEraseInDisplay(buffer *Buffer, params string) {
-	n, err := strconv.Atoi(params)
-	if err != nil {
-		n = 0
-	}
-	switch n {
+func handleEraseInDisplay(buffer *Buffer, t AnsiToken) {
+	switch t.Param(0, 0) {
 	case 0:
 		// Erase from the cursor to the end of the display
-		buffer.cells = buffer.cells[:buffer.cursorRow+1]
+		handleEraseInLine(buffer, t)
+		if buffer.cursorRow+1 < len(buffer.cells) {
+			buffer.cells = buffer.cells[:buffer.cursorRow+1]
+		}
 	case 1:
 		// Erase from the start of the display to the cursor
-		buffer.cells = buffer.cells[buffer.cursorRow+1:]
+		for row := buffer.top; row < buffer.cursorRow && row < len(buffer.cells); row++ {
+			buffer.cells[row] = nil
+		}
+		handleEraseInLine(buffer, t)
 	case 2:
-		// Erase the entire display
-		buffer.cells = make([][]Cell, 0)
+		// Erase the entire display, the scrollback stays
+		if buffer.top < len(buffer.cells) {
+			buffer.cells = buffer.cells[:buffer.top]
+		}
+	case 3:
+		// Erase the scrollback, the cursor stays on the same screen row
+		if buffer.top < len(buffer.cells) {
+			buffer.cells = buffer.cells[buffer.top:]
+		} else {
+			buffer.cells = make([][]Cell, 0)
+		}
+		buffer.cursorRow -= buffer.top
+		buffer.top = 0
+		buffer.valid = 0
 	}
 }
 
 // handleEraseInLine erases the line
-func handleEraseInLine(buffer *Buffer, params string) {
-	n, err := strconv.Atoi(params)
-	if err != nil {
-		n = 0
+func handleEraseInLine(buffer *Buffer, t AnsiToken) {
+	if buffer.cursorRow >= len(buffer.cells) {
+		return
 	}
-	switch n {
+	row := buffer.cells[buffer.cursorRow]
+	switch t.Param(0, 0) {
 	case 0:
 		// Erase from the cursor to the end of the line
-		buffer.cells[buffer.cursorRow] = buffer.cells[buffer.cursorRow][:buffer.cursorCol]
+		if buffer.cursorCol < len(row) {
+			buffer.cells[buffer.cursorRow] = row[:buffer.cursorCol]
+		}
 	case 1:
 		// Erase from the start of the line to the cursor
-		buffer.cells[buffer.cursorRow] = buffer.cells[buffer.cursorRow][buffer.cursorCol:]
+		for col := 0; col <= buffer.cursorCol && col < len(row); col++ {
+			row[col] = Cell{Rune: ' '}
+		}
 	case 2:
 		// Erase the entire line
-		buffer.cells[buffer.cursorRow] = make([]Cell, buffer.cursorCol)
+		buffer.cells[buffer.cursorRow] = nil
 	}
 }
 
-var stylingPattern = regexp.MustCompile(`^\x1b\[[0-9;]*m$`)
-
-// isStylingSequence checks if an ANSI sequence is for styling
-func isStylingSequence(sequence string) bool {
-	return stylingPattern.MatchString(sequence)
-}
-
 // handleStyling processes styling sequences
-func handleStyling(buffer *Buffer, sequence string) {
-	buffer.currentStyle += sequence
+func handleStyling(buffer *Buffer, t AnsiToken) {
+	buffer.currentStyle.apply(t.Params)
 }
 
-// renderBuffer converts the buffer back to a string
-func renderBuffer(buffer *Buffer) string {
+// renderRow converts a row of the buffer to a string. Styles and links end
+// with the row, so every row can be used on its own.
+func renderRow(row []Cell) string {
 	var result strings.Builder
-	var lastStyle string
-
-	for rowIndex, row := range buffer.cells {
-		if rowIndex > 0 {
-			result.WriteString("\n")
+	var lastStyle Style
+	var lastLink string
+
+	// Trim trailing empty cells
+	lastNonEmpty := -1
+	for i := len(row) - 1; i >= 0; i-- {
+		if row[i].Rune != ' ' || row[i].Style != (Style{}) {
+			lastNonEmpty = i
+			break
 		}
+	}
 
-		// Trim trailing empty cells
-		lastNonEmpty := -1
-		for i := len(row) - 1; i >= 0; i-- {
-			if row[i].Rune != ' ' || row[i].Style != "" {
-				lastNonEmpty = i
-				break
-			}
+	for colIndex := 0; colIndex <= lastNonEmpty; colIndex++ {
+		cell := row[colIndex]
+
+		// The right half of a wide character is drawn by the left one. A
+		// half that lost its other half is drawn as a space.
+		if cell.Continuation && colIndex > 0 && row[colIndex-1].Wide {
+			continue
+		}
+		text := cell.Text()
+		if cell.Continuation || (cell.Wide && (colIndex+1 >= len(row) || !row[colIndex+1].Continuation)) {
+			text = " "
 		}
 
-		for colIndex := 0; colIndex <= lastNonEmpty; colIndex++ {
-			cell := row[colIndex]
+		// Apply link and style changes
+		result.WriteString(linkChange(cell.Link, lastLink))
+		lastLink = cell.Link
+		result.WriteString(cell.Style.diff(lastStyle))
+		lastStyle = cell.Style
 
-			if colIndex != 0 && colIndex%buffer.softWrap == 0 {
-				result.WriteString("\n")
-				lastStyle = ""
-			}
+		result.WriteString(text)
+	}
 
-			// Apply style changes
-			if cell.Style != lastStyle {
-				if lastStyle != "" {
-					result.WriteString("\x1b[0m") // Reset previous style
-				}
-				result.WriteString(cell.Style)
-				lastStyle = cell.Style
-			}
+	// Reset at the end
+	result.WriteString(Style{}.diff(lastStyle))
+	result.WriteString(linkChange("", lastLink))
 
-			result.WriteRune(cell.Rune)
-		}
+	return result.String()
+}
+
+// linkChange returns the OSC 8 sequence that changes the link from prev to
+// link
+func linkChange(link, prev string) string {
+	if link == prev {
+		return ""
 	}
+	return "\x1b]8;;" + link + "\x1b\\"
+}
 
-	// Reset at the end
-	if lastStyle != "" {
-		result.WriteString("\x1b[0m")
+func isEmptyRow(row []Cell) bool {
+	for _, cell := range row {
+		if cell.Rune != ' ' || cell.Style != (Style{}) {
+			return false
+		}
 	}
+	return true
+}
 
-	return result.String()
+// decSpecialGraphics maps the DEC special graphics character set, used for
+// line drawing, to Unicode.
+func decSpecialGraphics(r rune) rune {
+	if r < 0x5f || r > 0x7e {
+		return r
+	}
+	return []rune("\u00a0◆▒␉␌␍␊°±␤␋┘┐┌└┼⎺⎻─⎼⎽├┤┴┬│≤≥π≠£·")[r-0x5f]
 }
@@ -1,9 +1,11 @@
 package ansicompiler_test
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/tsukinoko-kun/ohmygosh/internal/term"
 	"github.com/tsukinoko-kun/ohmygosh/internal/ui/ansicompiler"
 )
 
@@ -119,3 +121,762 @@ func TestCompileAnsiProgressBarExample(t *testing.T) {
 		t.Errorf("Expected %q, got %q", expected, result)
 	}
 }
+
+func TestParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string // chunks fed to the parser one after another
+		expected []ansicompiler.Token
+	}{
+		{
+			name:  "ground",
+			input: []string{"a\n"},
+			expected: []ansicompiler.Token{
+				ansicompiler.RuneToken{Rune: 'a'},
+				ansicompiler.RuneToken{Rune: '\n'},
+			},
+		},
+		{
+			name:  "escape",
+			input: []string{"\x1b7"},
+			expected: []ansicompiler.Token{
+				ansicompiler.AnsiToken{Sequence: "\x1b7", Kind: ansicompiler.KindESC, Final: '7'},
+			},
+		},
+		{
+			name:  "escape intermediate",
+			input: []string{"\x1b(B"},
+			expected: []ansicompiler.Token{
+				ansicompiler.AnsiToken{Sequence: "\x1b(B", Kind: ansicompiler.KindESC, Intermediates: "(", Final: 'B'},
+			},
+		},
+		{
+			name:  "csi params",
+			input: []string{"\x1b[2;;5H"},
+			expected: []ansicompiler.Token{
+				ansicompiler.AnsiToken{Sequence: "\x1b[2;;5H", Kind: ansicompiler.KindCSI, Final: 'H', Params: [][]int{{2}, {-1}, {5}}},
+			},
+		},
+		{
+			name:  "csi private",
+			input: []string{"\x1b[?25l"},
+			expected: []ansicompiler.Token{
+				ansicompiler.AnsiToken{Sequence: "\x1b[?25l", Kind: ansicompiler.KindCSI, Private: '?', Final: 'l', Params: [][]int{{25}}},
+			},
+		},
+		{
+			name:  "csi sub-parameters",
+			input: []string{"\x1b[38:2::1:2:3m"},
+			expected: []ansicompiler.Token{
+				ansicompiler.AnsiToken{Sequence: "\x1b[38:2::1:2:3m", Kind: ansicompiler.KindCSI, Final: 'm', Params: [][]int{{38, 2, -1, 1, 2, 3}}},
+			},
+		},
+		{
+			name:  "csi intermediate",
+			input: []string{"\x1b[2 q"},
+			expected: []ansicompiler.Token{
+				ansicompiler.AnsiToken{Sequence: "\x1b[2 q", Kind: ansicompiler.KindCSI, Intermediates: " ", Final: 'q', Params: [][]int{{2}}},
+			},
+		},
+		{
+			name:     "csi ignore",
+			input:    []string{"\x1b[1<2m"},
+			expected: nil,
+		},
+		{
+			name:  "control inside csi",
+			input: []string{"\x1b[1\r2A"},
+			expected: []ansicompiler.Token{
+				ansicompiler.RuneToken{Rune: '\r'},
+				ansicompiler.AnsiToken{Sequence: "\x1b[12A", Kind: ansicompiler.KindCSI, Final: 'A', Params: [][]int{{12}}},
+			},
+		},
+		{
+			name:  "cancel",
+			input: []string{"\x1b[12\x18a"},
+			expected: []ansicompiler.Token{
+				ansicompiler.RuneToken{Rune: 'a'},
+			},
+		},
+		{
+			name:  "escape restarts sequence",
+			input: []string{"\x1b[3\x1b[1m"},
+			expected: []ansicompiler.Token{
+				ansicompiler.AnsiToken{Sequence: "\x1b[1m", Kind: ansicompiler.KindCSI, Final: 'm', Params: [][]int{{1}}},
+			},
+		},
+		{
+			name:  "split across chunks",
+			input: []string{"\x1b[3", "1m", "x"},
+			expected: []ansicompiler.Token{
+				ansicompiler.AnsiToken{Sequence: "\x1b[31m", Kind: ansicompiler.KindCSI, Final: 'm', Params: [][]int{{31}}},
+				ansicompiler.RuneToken{Rune: 'x'},
+			},
+		},
+		{
+			name:  "osc with bell",
+			input: []string{"\x1b]0;title\x07x"},
+			expected: []ansicompiler.Token{
+				ansicompiler.AnsiToken{Sequence: "\x1b]0;title\x1b\\", Kind: ansicompiler.KindOSC, Data: "0;title"},
+				ansicompiler.RuneToken{Rune: 'x'},
+			},
+		},
+		{
+			name:  "osc with string terminator",
+			input: []string{"\x1b]8;;http://example.com\x1b", "\\"},
+			expected: []ansicompiler.Token{
+				ansicompiler.AnsiToken{Sequence: "\x1b]8;;http://example.com\x1b\\", Kind: ansicompiler.KindOSC, Data: "8;;http://example.com"},
+			},
+		},
+		{
+			name:  "dcs",
+			input: []string{"\x1bP1$qm\x1b\\"},
+			expected: []ansicompiler.Token{
+				ansicompiler.AnsiToken{Sequence: "\x1bP1$qm\x1b\\", Kind: ansicompiler.KindDCS, Intermediates: "$", Final: 'q', Params: [][]int{{1}}, Data: "m"},
+			},
+		},
+		{
+			name:     "dcs ignore",
+			input:    []string{"\x1bP1$1q data\x1b\\"},
+			expected: nil,
+		},
+		{
+			name:  "sos pm apc",
+			input: []string{"\x1bXa\x1b\\\x1b^b\x1b\\\x1b_Gf=100\x1b\\"},
+			expected: []ansicompiler.Token{
+				ansicompiler.AnsiToken{Sequence: "\x1bXa\x1b\\", Kind: ansicompiler.KindSOS, Data: "a"},
+				ansicompiler.AnsiToken{Sequence: "\x1b^b\x1b\\", Kind: ansicompiler.KindPM, Data: "b"},
+				ansicompiler.AnsiToken{Sequence: "\x1b_Gf=100\x1b\\", Kind: ansicompiler.KindAPC, Data: "Gf=100"},
+			},
+		},
+		{
+			name:  "c1 controls",
+			input: []string{"\u009b31m\u009d0;t\u009c\u0085"},
+			expected: []ansicompiler.Token{
+				ansicompiler.AnsiToken{Sequence: "\x1b[31m", Kind: ansicompiler.KindCSI, Final: 'm', Params: [][]int{{31}}},
+				ansicompiler.AnsiToken{Sequence: "\x1b]0;t\x1b\\", Kind: ansicompiler.KindOSC, Data: "0;t"},
+				ansicompiler.AnsiToken{Sequence: "\x1bE", Kind: ansicompiler.KindESC, Final: 'E'},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tokens []ansicompiler.Token
+			p := ansicompiler.NewParser()
+			for _, chunk := range tt.input {
+				p.Parse(chunk, func(token ansicompiler.Token) {
+					tokens = append(tokens, token)
+				})
+			}
+			if !reflect.DeepEqual(tokens, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, tokens)
+			}
+		})
+	}
+}
+
+func TestCompileAnsiSequences(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "window title",
+			input:    "a\x1b]0;title\x07b",
+			expected: "ab",
+		},
+		{
+			name:     "save and restore cursor",
+			input:    "a\x1b7bc\x1b8x",
+			expected: "axc",
+		},
+		{
+			name:     "reverse index",
+			input:    "line1\nline2\x1bMx",
+			expected: "line1x\nline2",
+		},
+		{
+			name:     "charset selection",
+			input:    "\x1b(0lqk\x1b(Bx",
+			expected: "┌─┐x",
+		},
+		{
+			name:     "8-bit csi",
+			input:    "ab\u009b1Dx",
+			expected: "ax",
+		},
+		{
+			name:     "private mode",
+			input:    "\x1b[?25la\x1b[?25h",
+			expected: "a",
+		},
+		{
+			name:     "backspace",
+			input:    "ab\bx",
+			expected: "ax",
+		},
+		{
+			name:     "bell",
+			input:    "a\x07b",
+			expected: "ab",
+		},
+		{
+			name:     "erase below the last line",
+			input:    "a\n\n\x1b[K\x1b[J",
+			expected: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ansicompiler.CompileAnsi(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCompileAnsiScreenOperations(t *testing.T) {
+	rows := term.Rows
+	term.Rows = 5
+	t.Cleanup(func() { term.Rows = rows })
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "insert lines",
+			input:    "l1\nl2\nl3\x1b[2;1H\x1b[L",
+			expected: "l1\n\nl2\nl3",
+		},
+		{
+			name:     "delete lines",
+			input:    "l1\nl2\nl3\x1b[1;1H\x1b[Mx",
+			expected: "x2\nl3",
+		},
+		{
+			name:     "insert characters",
+			input:    "abcd\x1b[1;2H\x1b[2@",
+			expected: "a  bcd",
+		},
+		{
+			name:     "delete characters",
+			input:    "abcd\x1b[1;2H\x1b[2P",
+			expected: "ad",
+		},
+		{
+			name:     "erase characters",
+			input:    "abcd\x1b[1;2H\x1b[2X",
+			expected: "a  d",
+		},
+		{
+			name:     "scroll up keeps scrollback",
+			input:    "l1\nl2\nl3\nl4\nl5\x1b[S\x1b[Hx",
+			expected: "l1\nx2\nl3\nl4\nl5",
+		},
+		{
+			name:     "scroll down",
+			input:    "l1\nl2\nl3\nl4\nl5\x1b[T",
+			expected: "\nl1\nl2\nl3\nl4",
+		},
+		{
+			name:     "cursor home after output scrolled",
+			input:    "l1\nl2\nl3\nl4\nl5\nl6\x1b[Hx",
+			expected: "l1\nx2\nl3\nl4\nl5\nl6",
+		},
+		{
+			name:     "scroll region",
+			input:    "header\n\n\nfooter\x1b[2;3r\x1b[2;1Ha\nb\nc",
+			expected: "header\nb\nc\nfooter",
+		},
+		{
+			name:     "reverse index at top margin",
+			input:    "l1\nl2\x1b[H\x1bMx",
+			expected: "x\nl1\nl2",
+		},
+		{
+			name:     "progress display redrawn in place",
+			input:    "step 1\nstep 2\n\x1b[2A\x1b[2Kdone 1\n\x1b[2Kdone 2\n",
+			expected: "done 1\ndone 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ansicompiler.CompileAnsi(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCompileAnsiStyles(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "attributes and color",
+			input:    "\x1b[1;31mA\x1b[0mB",
+			expected: "\x1b[1;31mA\x1b[0mB",
+		},
+		{
+			name:     "repeated sequences don't accumulate",
+			input:    "\x1b[31mA\x1b[31mB\x1b[32mC\x1b[0m",
+			expected: "\x1b[31mAB\x1b[32mC\x1b[0m",
+		},
+		{
+			name:     "reset clears previous attributes",
+			input:    "\x1b[1mA\x1b[0m\x1b[4mB",
+			expected: "\x1b[1mA\x1b[22;4mB\x1b[0m",
+		},
+		{
+			name:     "bold off keeps dim",
+			input:    "\x1b[1;2mA\x1b[22;2mB",
+			expected: "\x1b[1;2mA\x1b[22;2mB\x1b[0m",
+		},
+		{
+			name:     "256 colors",
+			input:    "\x1b[38;5;208;48;5;17mA",
+			expected: "\x1b[38;5;208;48;5;17mA\x1b[0m",
+		},
+		{
+			name:     "true color with colons",
+			input:    "\x1b[38:2::1:2:3mA",
+			expected: "\x1b[38;2;1;2;3mA\x1b[0m",
+		},
+		{
+			name:     "bright colors",
+			input:    "\x1b[44;97mA",
+			expected: "\x1b[97;44mA\x1b[0m",
+		},
+		{
+			name:     "default foreground",
+			input:    "\x1b[31;44mA\x1b[39mB",
+			expected: "\x1b[31;44mA\x1b[39mB\x1b[0m",
+		},
+		{
+			name:     "underline style and color",
+			input:    "\x1b[4:3;58;2;255;0;0mA\x1b[24mB",
+			expected: "\x1b[4:3;58;2;255;0;0mA\x1b[24mB\x1b[0m",
+		},
+		{
+			name:     "empty sequence resets",
+			input:    "\x1b[7mA\x1b[mB",
+			expected: "\x1b[7mA\x1b[0mB",
+		},
+		{
+			name:     "styles end with the line",
+			input:    "\x1b[31mA\nB\x1b[0m",
+			expected: "\x1b[31mA\x1b[0m\n\x1b[31mB\x1b[0m",
+		},
+		{
+			name:     "private sequences are ignored",
+			input:    "\x1b[>4;1mA",
+			expected: "A",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ansicompiler.CompileAnsi(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCompileAnsiWideCharacters(t *testing.T) {
+	cols := term.Cols
+	term.Cols = 6
+	t.Cleanup(func() { term.Cols = cols })
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "wide characters take two columns",
+			input:    "日本\x1b[1;5Hx",
+			expected: "日本x",
+		},
+		{
+			name:     "overwrite left half",
+			input:    "日本\x1b[1;3Hx",
+			expected: "日x",
+		},
+		{
+			name:     "overwrite right half",
+			input:    "日本\x1b[1;2Hx",
+			expected: " x本",
+		},
+		{
+			name:     "combining mark joins the previous character",
+			input:    "e\u0301\x1b[1;2Hx",
+			expected: "e\u0301x",
+		},
+		{
+			name:     "combining mark after cursor movement",
+			input:    "ab\x1b[1;2H\u0301",
+			expected: "a\u0301b",
+		},
+		{
+			name:     "joined emoji are one cell",
+			input:    "👩\u200d💻\x1b[1;3Hx",
+			expected: "👩\u200d💻x",
+		},
+		{
+			name:     "variation selector widens the character",
+			input:    "❤\ufe0f\x1b[1;3Hx",
+			expected: "❤\ufe0fx",
+		},
+		{
+			name:     "wide character doesn't split at the wrap",
+			input:    "abcde日",
+			expected: "abcde\n日",
+		},
+		{
+			name:     "deleted half is drawn as space",
+			input:    "日x\x1b[1;2H\x1b[P",
+			expected: " x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ansicompiler.CompileAnsi(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCompileAnsiTabsAndWrapping(t *testing.T) {
+	cols := term.Cols
+	term.Cols = 20
+	t.Cleanup(func() { term.Cols = cols })
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "default tab stops",
+			input:    "a\tb\tc",
+			expected: "a       b       c",
+		},
+		{
+			name:     "tab keeps existing content",
+			input:    "abcdefghij\r\tX",
+			expected: "abcdefghXj",
+		},
+		{
+			name:     "tab stops at the last column",
+			input:    "\t\t\t\tx",
+			expected: "                   x",
+		},
+		{
+			name:     "set and clear tab stops",
+			input:    "\x1b[3g\x1b[4G\x1bH\ra\tb\x1b[0g\ra\tc",
+			expected: "a  c",
+		},
+		{
+			name:     "forward and backward tabulation",
+			input:    "\x1b[2Ix\x1b[2Zy",
+			expected: "        y       x",
+		},
+		{
+			name:     "autowrap at write time",
+			input:    "0123456789abcdefghijXY",
+			expected: "0123456789abcdefghij\nXY",
+		},
+		{
+			name:     "cursor stays in the last column until the next character",
+			input:    "0123456789abcdefghij\rX",
+			expected: "X123456789abcdefghij",
+		},
+		{
+			name:     "cursor movement after wrapped lines",
+			input:    "0123456789abcdefghijXY\x1b[A\x1b[1GZ",
+			expected: "Z123456789abcdefghij\nXY",
+		},
+		{
+			name:     "no autowrap overwrites the last column",
+			input:    "\x1b[?7l0123456789abcdefghijXY\x1b[?7h",
+			expected: "0123456789abcdefghiY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ansicompiler.CompileAnsi(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCompileAnsiLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "link is re-emitted",
+			input:    "see \x1b]8;;https://example.com\x1b\\here\x1b]8;;\x1b\\.",
+			expected: "see \x1b]8;;https://example.com\x1b\\here\x1b]8;;\x1b\\.",
+		},
+		{
+			name:     "link with parameters and BEL",
+			input:    "\x1b]8;id=1;file:///tmp\x07tmp\x1b]8;;\x07",
+			expected: "\x1b]8;;file:///tmp\x1b\\tmp\x1b]8;;\x1b\\",
+		},
+		{
+			name:     "links end with the line",
+			input:    "\x1b]8;;https://a\x1b\\a\nb\x1b]8;;\x1b\\",
+			expected: "\x1b]8;;https://a\x1b\\a\x1b]8;;\x1b\\\n\x1b]8;;https://a\x1b\\b\x1b]8;;\x1b\\",
+		},
+		{
+			name:     "other OSC sequences are dropped",
+			input:    "\x1b]0;title\x07text",
+			expected: "text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ansicompiler.CompileAnsi(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSafeLink(t *testing.T) {
+	tests := []struct {
+		link string
+		safe bool
+	}{
+		{"https://example.com/a?b=c", true},
+		{"HTTP://example.com", true},
+		{"mailto:me@example.com", true},
+		{"https:///path", false},
+		{"mailto:", false},
+		{"file:///etc/passwd", false},
+		{"javascript:alert(1)", false},
+		{"-oProxyCommand=x", false},
+		{"/tmp/x", false},
+	}
+	for _, tt := range tests {
+		if got := ansicompiler.SafeLink(tt.link); got != tt.safe {
+			t.Errorf("SafeLink(%q) = %v, want %v", tt.link, got, tt.safe)
+		}
+	}
+}
+
+func TestBufferLinkAt(t *testing.T) {
+	buffer := ansicompiler.Compile("ab \x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\\nnone")
+	tests := []struct {
+		row, col int
+		expected string
+	}{
+		{0, 1, ""},
+		{0, 3, "https://example.com"},
+		{0, 6, "https://example.com"},
+		{0, 7, ""},
+		{1, 0, ""},
+		{5, 0, ""},
+	}
+	for _, tt := range tests {
+		if link := buffer.LinkAt(tt.row, tt.col); link != tt.expected {
+			t.Errorf("LinkAt(%d, %d) = %q, expected %q", tt.row, tt.col, link, tt.expected)
+		}
+	}
+	if !buffer.HasLinks(0) || buffer.HasLinks(1) {
+		t.Errorf("HasLinks reports the wrong rows")
+	}
+}
+
+func TestBufferWrite(t *testing.T) {
+	rows := term.Rows
+	term.Rows = 3
+	t.Cleanup(func() { term.Rows = rows })
+
+	inputs := []string{
+		"\x1b[1;31mred\x1b[0m 日本 é\r\nline 2\n",
+		"l1\nl2\nl3\nl4\nl5\x1b[3J\x1b[Hx",
+		"\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\\x1bcafter reset",
+	}
+
+	for _, input := range inputs {
+		expected := ansicompiler.CompileAnsi(input)
+		// every split must give the same result, even inside sequences and
+		// characters
+		for i := 0; i <= len(input); i++ {
+			buffer := ansicompiler.NewBuffer()
+			_, _ = buffer.Write([]byte(input[:i]))
+			_ = buffer.Render()
+			_, _ = buffer.Write([]byte(input[i:]))
+			if result := buffer.Render(); result != expected {
+				t.Errorf("split at %d of %q: expected %q, got %q", i, input, expected, result)
+			}
+		}
+	}
+}
+
+func TestBufferScrollback(t *testing.T) {
+	rows := term.Rows
+	term.Rows = 3
+	t.Cleanup(func() { term.Rows = rows })
+
+	var spill strings.Builder
+	buffer := ansicompiler.NewBuffer()
+	buffer.SetScrollback(2, &spill)
+	_, _ = buffer.WriteString("l1\nl2\n\x1b[31ml3\x1b[0m\nl4\n")
+	_ = buffer.Render()
+	_, _ = buffer.WriteString("l5\nl6\nl7\x1b[1;1Hx")
+
+	if expected := "l1\nl2\n"; spill.String() != expected {
+		t.Errorf("Expected spilled %q, got %q", expected, spill.String())
+	}
+	if buffer.Truncated() != 2 {
+		t.Errorf("Expected 2 truncated rows, got %d", buffer.Truncated())
+	}
+	if expected, result := "\x1b[31ml3\x1b[0m\nl4\nx5\nl6\nl7", buffer.Render(); result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestBufferAltScreen(t *testing.T) {
+	rows, cols := term.Rows, term.Cols
+	term.Rows, term.Cols = 3, 10
+	t.Cleanup(func() { term.Rows, term.Cols = rows, cols })
+
+	tests := []struct {
+		name   string
+		input  string
+		alt    bool
+		output string
+	}{
+		{
+			name:   "enter",
+			input:  "a\nb\x1b[?1049h\x1b[Hx",
+			alt:    true,
+			output: "x\n\n",
+		},
+		{
+			name:   "no scrollback",
+			input:  "a\x1b[?1049h1\n2\n3\n4",
+			alt:    true,
+			output: "2\n3\n4",
+		},
+		{
+			name:   "leave",
+			input:  "a\nb\x1b[?1049h\x1b[Hx\x1b[?1049lc",
+			output: "a\nbc",
+		},
+		{
+			name:   "older mode",
+			input:  "a\x1b[?47hx\x1b[?47l",
+			output: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := ansicompiler.Compile(tt.input)
+			if buffer.AltScreen() != tt.alt {
+				t.Errorf("Expected alternate screen %v", tt.alt)
+			}
+			if result := buffer.Render(); result != tt.output {
+				t.Errorf("Expected %q, got %q", tt.output, result)
+			}
+		})
+	}
+}
+
+func TestBufferResize(t *testing.T) {
+	rows, cols := term.Rows, term.Cols
+	term.Rows, term.Cols = 3, 10
+	t.Cleanup(func() { term.Rows, term.Cols = rows, cols })
+
+	buffer := ansicompiler.Compile("\x1b[?1049h1\n2\n3")
+	buffer.Resize(4, 2)
+	_, _ = buffer.WriteString("45")
+	if expected, result := "2\n345", buffer.Render(); result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+
+	_, _ = buffer.WriteString("\x1b[?25h")
+	if row, line, ok := buffer.CursorLine(); !ok || row != 1 || line != "345\x1b[7m \x1b[0m" {
+		t.Errorf("Unexpected cursor line %d %q %v", row, line, ok)
+	}
+}
+
+func TestBufferScrollbackEmptyRows(t *testing.T) {
+	rows := term.Rows
+	term.Rows = 3
+	t.Cleanup(func() { term.Rows = rows })
+
+	tests := []struct {
+		name  string
+		write func(buffer *ansicompiler.Buffer)
+		spill int
+	}{
+		{
+			name: "line feeds",
+			write: func(buffer *ansicompiler.Buffer) {
+				_, _ = buffer.WriteString("a" + strings.Repeat("\n", 20))
+				_, _ = buffer.WriteString("b")
+			},
+			spill: 13,
+		},
+		{
+			name: "scroll up",
+			write: func(buffer *ansicompiler.Buffer) {
+				_, _ = buffer.WriteString("a\x1b[65535S\x1b[65535T\x1b[3;1Hb")
+			},
+			spill: 0,
+		},
+		{
+			name: "resize",
+			write: func(buffer *ansicompiler.Buffer) {
+				_, _ = buffer.WriteString("a")
+				buffer.Resize(10, 100)
+				_, _ = buffer.WriteString("\x1b[100;1H")
+				buffer.Resize(10, 3)
+				_, _ = buffer.WriteString("b")
+			},
+			spill: 92,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var spill strings.Builder
+			buffer := ansicompiler.NewBuffer()
+			buffer.SetScrollback(5, &spill)
+			tt.write(buffer)
+			if spilled := strings.Count(spill.String(), "\n"); spilled != tt.spill {
+				t.Errorf("Expected %d spilled rows, got %d: %q", tt.spill, spilled, spill.String())
+			}
+			if result := buffer.Render(); !strings.HasSuffix(result, "\nb") {
+				t.Errorf("Expected b on the last row, got %q", result)
+			}
+		})
+	}
+}
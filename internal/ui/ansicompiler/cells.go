@@ -0,0 +1,127 @@
+package ansicompiler
+
+import (
+	"github.com/rivo/uniseg"
+)
+
+// lastCell is the cell the last character was written to. A following rune
+// that continues its grapheme cluster is added to it as long as the cursor
+// didn't move.
+type lastCell struct {
+	row, col int
+	// end is the column of the cursor after the character was written
+	end int
+	ok  bool
+}
+
+// Text returns the grapheme cluster of the cell
+func (c Cell) Text() string {
+	return string(c.Rune) + c.Combining
+}
+
+// runeWidth returns the number of columns r takes on its own
+func runeWidth(r rune) int {
+	if r < 0x20 {
+		// tabs are written as they are
+		return 1
+	}
+	return uniseg.StringWidth(string(r))
+}
+
+// joinCluster adds r to the last written cell if it extends its grapheme
+// cluster, like combining marks, variation selectors and joined emoji do
+func (b *Buffer) joinCluster(r rune) bool {
+	last := b.last
+	if !last.ok || b.cursorRow != last.row || b.cursorCol != last.end ||
+		last.row >= len(b.cells) || last.col >= len(b.cells[last.row]) {
+		return false
+	}
+
+	cell := &b.cells[last.row][last.col]
+	text := cell.Text() + string(r)
+	cluster, _, width, _ := uniseg.FirstGraphemeClusterInString(text, -1)
+	if len(cluster) != len(text) {
+		return false
+	}
+	cell.Combining += string(r)
+
+	// a variation selector can turn a narrow character into a wide one
+	if width == 2 && !cell.Wide && last.col+1 < b.width {
+		b.ensureSize(last.row, last.col+1)
+		b.clearWide(last.row, last.col+1)
+		cell = &b.cells[last.row][last.col]
+		cell.Wide = true
+		b.cells[last.row][last.col+1] = Cell{Rune: ' ', Continuation: true, Style: cell.Style, Link: cell.Link}
+		b.cursorCol = last.col + 2
+		if b.cursorCol >= b.width {
+			b.cursorCol = b.width - 1
+			b.wrapNext = true
+		}
+		b.last.end = b.cursorCol
+	}
+	return true
+}
+
+// combine adds a zero width rune to the character left of the cursor, it is
+// dropped if there is none
+func (b *Buffer) combine(r rune) {
+	if b.cursorRow >= len(b.cells) {
+		return
+	}
+	row := b.cells[b.cursorRow]
+	col := min(b.cursorCol, len(row)) - 1
+	if col >= 0 && row[col].Continuation {
+		col--
+	}
+	if col < 0 {
+		return
+	}
+	row[col].Combining += string(r)
+}
+
+// putCell writes a character that is width columns wide at the cursor and
+// advances the cursor
+func (b *Buffer) putCell(r rune, width int) {
+	// a wide character that doesn't fit in the last column goes to the next
+	// line, or overwrites the last two columns without autowrap
+	if b.cursorCol+width > b.width {
+		if b.autowrap && width <= b.width {
+			b.lineFeed()
+			b.cursorCol = 0
+		} else {
+			b.cursorCol = max(0, b.width-width)
+		}
+	}
+
+	row, col := b.cursorRow, b.cursorCol
+	b.ensureSize(row, col+width-1)
+	for i := 0; i < width; i++ {
+		b.clearWide(row, col+i)
+	}
+	b.cells[row][col] = Cell{Rune: r, Wide: width == 2, Style: b.currentStyle, Link: b.currentLink}
+	if width == 2 {
+		b.cells[row][col+1] = Cell{Rune: ' ', Continuation: true, Style: b.currentStyle, Link: b.currentLink}
+	}
+	b.cursorCol += width
+	if b.cursorCol >= b.width {
+		// the cursor stays in the last column until the next character
+		b.cursorCol = b.width - 1
+		b.wrapNext = true
+	}
+	b.last = lastCell{row: row, col: col, end: b.cursorCol, ok: true}
+}
+
+// clearWide blanks the other half of a wide character before one of its
+// halves is overwritten
+func (b *Buffer) clearWide(row, col int) {
+	line := b.cells[row]
+	if col >= len(line) {
+		return
+	}
+	switch {
+	case line[col].Continuation && col > 0:
+		line[col-1] = Cell{Rune: ' ', Style: line[col-1].Style}
+	case line[col].Wide && col+1 < len(line):
+		line[col+1] = Cell{Rune: ' ', Style: line[col+1].Style}
+	}
+}
@@ -0,0 +1,387 @@
+package ansicompiler
+
+import (
+	"strings"
+)
+
+// SequenceKind classifies an escape sequence.
+type SequenceKind uint8
+
+const (
+	// KindCSI is a control sequence: ESC [ or CSI, parameters, intermediates
+	// and a final character.
+	KindCSI SequenceKind = iota
+	// KindESC is an escape sequence: ESC, intermediates and a final character.
+	// 8-bit C1 controls are reported as their ESC equivalent.
+	KindESC
+	// KindOSC is an operating system command: ESC ] or OSC and a string.
+	KindOSC
+	// KindDCS is a device control string: ESC P or DCS, parameters,
+	// intermediates, a final character and a string.
+	KindDCS
+	// KindSOS is a start of string: ESC X or SOS and a string.
+	KindSOS
+	// KindPM is a privacy message: ESC ^ or PM and a string.
+	KindPM
+	// KindAPC is an application program command: ESC _ or APC and a string.
+	KindAPC
+)
+
+// MissingParam is the value of a parameter that was left empty.
+const MissingParam = -1
+
+// maxParams limits the parameters of a sequence like terminals do, anything
+// beyond is dropped.
+const maxParams = 32
+
+type parserState uint8
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCsiEntry
+	stateCsiParam
+	stateCsiIntermediate
+	stateCsiIgnore
+	stateDcsEntry
+	stateDcsParam
+	stateDcsIntermediate
+	stateDcsPassthrough
+	stateDcsIgnore
+	stateOscString
+	stateSosPmApcString
+)
+
+// Parser splits terminal output into tokens.
+// It is a state machine modelled after Paul Williams' parser for DEC
+// compatible terminals (https://vt100.net/emu/dec_ansi_parser), so every
+// sequence is consumed completely, even the ones that are not understood.
+// The parser keeps its state between calls to Parse, a sequence may be split
+// across several chunks of output.
+type Parser struct {
+	state parserState
+	// stringEsc is set if an ESC was seen inside a string, it is either the
+	// start of ST or of a new sequence.
+	stringEsc bool
+	token     AnsiToken
+	raw       strings.Builder
+	data      strings.Builder
+	param     []int
+}
+
+// NewParser creates a parser in the ground state.
+func NewParser() *Parser {
+	return &Parser{}
+}
+
+// Parse feeds input to the parser and calls emit for every complete token.
+func (p *Parser) Parse(input string, emit func(Token)) {
+	for _, r := range input {
+		p.advance(r, emit)
+	}
+}
+
+func (p *Parser) advance(r rune, emit func(Token)) {
+	// strings end with ST, which is ESC \ in its 7-bit form
+	if p.stringEsc {
+		p.stringEsc = false
+		if r == '\\' {
+			p.finishString(emit)
+			p.state = stateGround
+			return
+		}
+		// any other sequence aborts the string
+		p.finishString(emit)
+		p.enter(stateEscape)
+	}
+
+	// transitions from anywhere
+	switch {
+	case r == 0x18 || r == 0x1a: // CAN, SUB
+		p.state = stateGround
+		return
+	case r == 0x1b:
+		if p.inString() {
+			p.stringEsc = true
+			return
+		}
+		p.enter(stateEscape)
+		return
+	case r == 0x9c: // ST
+		if p.inString() {
+			p.finishString(emit)
+		}
+		p.state = stateGround
+		return
+	case r >= 0x80 && r <= 0x9f:
+		if p.inString() {
+			p.finishString(emit)
+		}
+		p.c1(r, emit)
+		return
+	}
+
+	switch p.state {
+	case stateGround:
+		if r != 0x7f {
+			emit(RuneToken{Rune: r})
+		}
+
+	case stateEscape:
+		switch {
+		case isC0(r):
+			emit(RuneToken{Rune: r})
+		case r >= 0x20 && r <= 0x2f:
+			p.collect(r)
+			p.state = stateEscapeIntermediate
+		case r == '[':
+			p.raw.WriteRune(r)
+			p.enter(stateCsiEntry)
+		case r == ']':
+			p.raw.WriteRune(r)
+			p.enter(stateOscString)
+		case r == 'P':
+			p.raw.WriteRune(r)
+			p.enter(stateDcsEntry)
+		case r == 'X', r == '^', r == '_':
+			p.raw.WriteRune(r)
+			p.token.Kind = stringKind(r)
+			p.enter(stateSosPmApcString)
+		case r >= 0x30 && r <= 0x7e:
+			p.escDispatch(r, emit)
+		}
+
+	case stateEscapeIntermediate:
+		switch {
+		case isC0(r):
+			emit(RuneToken{Rune: r})
+		case r >= 0x20 && r <= 0x2f:
+			p.collect(r)
+		case r >= 0x30 && r <= 0x7e:
+			p.escDispatch(r, emit)
+		}
+
+	case stateCsiEntry, stateCsiParam:
+		switch {
+		case isC0(r):
+			emit(RuneToken{Rune: r})
+		case r >= '0' && r <= '9', r == ';', r == ':':
+			p.raw.WriteRune(r)
+			p.paramRune(r)
+			p.state = stateCsiParam
+		case r >= 0x3c && r <= 0x3f:
+			// private markers are only allowed before the parameters
+			if p.state != stateCsiEntry {
+				p.state = stateCsiIgnore
+				return
+			}
+			p.raw.WriteRune(r)
+			p.token.Private = byte(r)
+			p.state = stateCsiParam
+		case r >= 0x20 && r <= 0x2f:
+			p.collect(r)
+			p.state = stateCsiIntermediate
+		case r >= 0x40 && r <= 0x7e:
+			p.csiDispatch(r, emit)
+		}
+
+	case stateCsiIntermediate:
+		switch {
+		case isC0(r):
+			emit(RuneToken{Rune: r})
+		case r >= 0x20 && r <= 0x2f:
+			p.collect(r)
+		case r >= 0x30 && r <= 0x3f:
+			p.state = stateCsiIgnore
+		case r >= 0x40 && r <= 0x7e:
+			p.csiDispatch(r, emit)
+		}
+
+	case stateCsiIgnore:
+		switch {
+		case isC0(r):
+			emit(RuneToken{Rune: r})
+		case r >= 0x40 && r <= 0x7e:
+			p.state = stateGround
+		}
+
+	case stateDcsEntry, stateDcsParam:
+		switch {
+		case r >= '0' && r <= '9', r == ';', r == ':':
+			p.raw.WriteRune(r)
+			p.paramRune(r)
+			p.state = stateDcsParam
+		case r >= 0x3c && r <= 0x3f:
+			if p.state != stateDcsEntry {
+				p.state = stateDcsIgnore
+				return
+			}
+			p.raw.WriteRune(r)
+			p.token.Private = byte(r)
+			p.state = stateDcsParam
+		case r >= 0x20 && r <= 0x2f:
+			p.collect(r)
+			p.state = stateDcsIntermediate
+		case r >= 0x40 && r <= 0x7e:
+			p.hook(r)
+		}
+
+	case stateDcsIntermediate:
+		switch {
+		case r >= 0x20 && r <= 0x2f:
+			p.collect(r)
+		case r >= 0x30 && r <= 0x3f:
+			p.state = stateDcsIgnore
+		case r >= 0x40 && r <= 0x7e:
+			p.hook(r)
+		}
+
+	case stateDcsPassthrough, stateOscString, stateSosPmApcString:
+		// xterm also ends OSC with BEL
+		if r == 0x07 && p.state == stateOscString {
+			p.finishString(emit)
+			p.state = stateGround
+			return
+		}
+		// only DCS passes control characters through
+		if r == 0x7f || (isC0(r) && p.state != stateDcsPassthrough) {
+			return
+		}
+		p.data.WriteRune(r)
+
+	case stateDcsIgnore:
+		// everything up to ST is dropped
+	}
+}
+
+// c1 handles 8-bit C1 control characters like their 7-bit ESC equivalent.
+func (p *Parser) c1(r rune, emit func(Token)) {
+	p.enter(stateEscape)
+	p.advance(r-0x40, emit)
+}
+
+func (p *Parser) inString() bool {
+	switch p.state {
+	case stateOscString, stateSosPmApcString, stateDcsPassthrough, stateDcsIgnore:
+		return true
+	}
+	return false
+}
+
+// enter starts a new sequence in state.
+func (p *Parser) enter(state parserState) {
+	if state == stateEscape {
+		p.token = AnsiToken{}
+		p.raw.Reset()
+		p.raw.WriteByte(0x1b)
+		p.data.Reset()
+		p.param = nil
+	}
+	switch state {
+	case stateCsiEntry:
+		p.token.Kind = KindCSI
+	case stateDcsEntry:
+		p.token.Kind = KindDCS
+	case stateOscString:
+		p.token.Kind = KindOSC
+	}
+	p.state = state
+}
+
+func (p *Parser) collect(r rune) {
+	p.raw.WriteRune(r)
+	p.token.Intermediates += string(r)
+}
+
+// paramRune adds a digit or separator to the parameters. Parameters are
+// separated by ';', sub-parameters by ':'.
+func (p *Parser) paramRune(r rune) {
+	if p.param == nil {
+		p.param = []int{MissingParam}
+	}
+	switch r {
+	case ';':
+		p.finishParam()
+		p.param = []int{MissingParam}
+	case ':':
+		p.param = append(p.param, MissingParam)
+	default:
+		i := len(p.param) - 1
+		if p.param[i] == MissingParam {
+			p.param[i] = 0
+		}
+		// clamp instead of overflowing
+		if p.param[i] < 1<<16 {
+			p.param[i] = p.param[i]*10 + int(r-'0')
+		}
+	}
+}
+
+func (p *Parser) finishParam() {
+	if p.param != nil && len(p.token.Params) < maxParams {
+		p.token.Params = append(p.token.Params, p.param)
+	}
+	p.param = nil
+}
+
+func (p *Parser) escDispatch(r rune, emit func(Token)) {
+	p.raw.WriteRune(r)
+	p.token.Kind = KindESC
+	p.token.Final = r
+	p.token.Sequence = p.raw.String()
+	emit(p.token)
+	p.state = stateGround
+}
+
+func (p *Parser) csiDispatch(r rune, emit func(Token)) {
+	p.raw.WriteRune(r)
+	p.finishParam()
+	p.token.Final = r
+	p.token.Sequence = p.raw.String()
+	emit(p.token)
+	p.state = stateGround
+}
+
+// hook starts the data string of a DCS sequence.
+func (p *Parser) hook(r rune) {
+	p.raw.WriteRune(r)
+	p.finishParam()
+	p.token.Final = r
+	p.state = stateDcsPassthrough
+}
+
+func (p *Parser) finishString(emit func(Token)) {
+	if p.state == stateDcsIgnore {
+		return
+	}
+	p.token.Data = p.data.String()
+	p.raw.WriteString(p.token.Data)
+	p.raw.WriteString("\x1b\\")
+	p.token.Sequence = p.raw.String()
+	emit(p.token)
+}
+
+func isC0(r rune) bool {
+	return r < 0x20
+}
+
+func stringKind(r rune) SequenceKind {
+	switch r {
+	case 'X':
+		return KindSOS
+	case '^':
+		return KindPM
+	default:
+		return KindAPC
+	}
+}
+
+// Param returns the first sub-parameter of the i-th parameter or def if it
+// is missing.
+func (t AnsiToken) Param(i int, def int) int {
+	if i >= len(t.Params) || len(t.Params[i]) == 0 || t.Params[i][0] == MissingParam {
+		return def
+	}
+	return t.Params[i][0]
+}
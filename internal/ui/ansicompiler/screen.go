@@ -0,0 +1,374 @@
+package ansicompiler
+
+import (
+	"io"
+	"strings"
+)
+
+// ensureRow ensures the buffer has the given row
+func (b *Buffer) ensureRow(row int) {
+	for len(b.cells) <= row {
+		b.cells = append(b.cells, make([]Cell, 0))
+	}
+}
+
+// screenRow returns the row of the cursor on the screen
+func (b *Buffer) screenRow() int {
+	return b.cursorRow - b.top
+}
+
+// inScrollRegion reports whether the cursor is inside the scroll region
+func (b *Buffer) inScrollRegion() bool {
+	row := b.screenRow()
+	return row >= b.scrollTop && row <= b.scrollBottom
+}
+
+// saveCursor saves the cursor position relative to the screen
+func (b *Buffer) saveCursor() {
+	b.savedCursorRow = b.screenRow()
+	b.savedCursorCol = b.cursorCol
+}
+
+// restoreCursor restores the cursor position saved by saveCursor
+func (b *Buffer) restoreCursor() {
+	b.setCursor(b.top+b.savedCursorRow, b.savedCursorCol)
+}
+
+// moveRow moves the cursor up or down. It stops at the margins of the scroll
+// region if it is inside of it and at the edges of the screen otherwise.
+func (b *Buffer) moveRow(n int) {
+	low, high := 0, b.height-1
+	if b.inScrollRegion() {
+		low, high = b.scrollTop, b.scrollBottom
+	}
+	row := min(max(b.screenRow()+n, low), high)
+	b.cursorRow = b.top + row
+	b.wrapNext = false
+}
+
+// lineFeed moves the cursor down, scrolling the region at its bottom margin
+func (b *Buffer) lineFeed() {
+	row := b.screenRow()
+	switch {
+	case row == b.scrollBottom:
+		b.scrollUp(1)
+	case row < b.height-1:
+		b.cursorRow++
+	}
+}
+
+// reverseIndex moves the cursor up, scrolling the region at its top margin
+func (b *Buffer) reverseIndex() {
+	row := b.screenRow()
+	switch {
+	case row == b.scrollTop:
+		b.scrollDown(1)
+	case row > 0:
+		b.cursorRow--
+	}
+}
+
+// setScrollRegion sets the scroll region to the screen rows top to bottom
+// and moves the cursor home. Invalid regions are ignored.
+func (b *Buffer) setScrollRegion(top, bottom int) {
+	top = max(top, 0)
+	if bottom < 0 || bottom >= b.height {
+		bottom = b.height - 1
+	}
+	if top >= bottom {
+		return
+	}
+	b.scrollTop = top
+	b.scrollBottom = bottom
+	b.setCursor(b.top, 0)
+}
+
+// scrollUp moves the content of the scroll region up by n rows. If the region
+// is the whole main screen, the rows that leave it go to the scrollback.
+func (b *Buffer) scrollUp(n int) {
+	n = min(n, b.scrollBottom-b.scrollTop+1)
+	if b.primary == nil && b.scrollTop == 0 && b.scrollBottom == b.height-1 {
+		b.top += n
+		b.cursorRow += n
+		return
+	}
+	b.shiftRows(b.top+b.scrollTop, b.top+b.scrollBottom, -n)
+}
+
+// scrollDown moves the content of the scroll region down by n rows
+func (b *Buffer) scrollDown(n int) {
+	n = min(n, b.scrollBottom-b.scrollTop+1)
+	b.shiftRows(b.top+b.scrollTop, b.top+b.scrollBottom, n)
+}
+
+// insertLines inserts n empty rows at the cursor, rows pushed past the bottom
+// margin are lost
+func (b *Buffer) insertLines(n int) {
+	if !b.inScrollRegion() {
+		return
+	}
+	b.shiftRows(b.cursorRow, b.top+b.scrollBottom, n)
+	b.cursorCol = 0
+}
+
+// deleteLines deletes n rows at the cursor, empty rows are added at the
+// bottom margin
+func (b *Buffer) deleteLines(n int) {
+	if !b.inScrollRegion() {
+		return
+	}
+	b.shiftRows(b.cursorRow, b.top+b.scrollBottom, -n)
+	b.cursorCol = 0
+}
+
+// shiftRows moves the rows from to to (inclusive) down by n, or up for a
+// negative n. Rows moved out of the range are lost, rows moved in are empty.
+func (b *Buffer) shiftRows(from, to, n int) {
+	if from > to || n == 0 {
+		return
+	}
+
+	if n > 0 {
+		// rows below the content are empty, only grow as far as needed
+		last := min(to, len(b.cells)-1+n)
+		if last < from {
+			return
+		}
+		b.ensureRow(last)
+		region := b.cells[from : last+1]
+		n = min(n, len(region))
+		copy(region[n:], region)
+		for i := 0; i < n; i++ {
+			region[i] = nil
+		}
+		return
+	}
+
+	if from >= len(b.cells) {
+		return
+	}
+	region := b.cells[from : min(to, len(b.cells)-1)+1]
+	n = min(-n, len(region))
+	copy(region, region[n:])
+	for i := len(region) - n; i < len(region); i++ {
+		region[i] = nil
+	}
+}
+
+// insertChars inserts n blank cells at the cursor, moving the rest of the row
+// to the right
+func (b *Buffer) insertChars(n int) {
+	if b.cursorRow >= len(b.cells) || b.cursorCol >= len(b.cells[b.cursorRow]) {
+		return
+	}
+	row := b.cells[b.cursorRow]
+	limit := max(len(row), b.width)
+
+	inserted := make([]Cell, 0, len(row)+n)
+	inserted = append(inserted, row[:b.cursorCol]...)
+	for i := 0; i < n; i++ {
+		inserted = append(inserted, Cell{Rune: ' '})
+	}
+	inserted = append(inserted, row[b.cursorCol:]...)
+	if len(inserted) > limit {
+		inserted = inserted[:limit]
+	}
+	b.cells[b.cursorRow] = inserted
+}
+
+// deleteChars deletes n cells at the cursor, moving the rest of the row to
+// the left
+func (b *Buffer) deleteChars(n int) {
+	if b.cursorRow >= len(b.cells) || b.cursorCol >= len(b.cells[b.cursorRow]) {
+		return
+	}
+	row := b.cells[b.cursorRow]
+	end := min(b.cursorCol+n, len(row))
+	b.cells[b.cursorRow] = append(row[:b.cursorCol], row[end:]...)
+}
+
+// eraseChars blanks n cells starting at the cursor
+func (b *Buffer) eraseChars(n int) {
+	if b.cursorRow >= len(b.cells) {
+		return
+	}
+	row := b.cells[b.cursorRow]
+	for col := b.cursorCol; col < b.cursorCol+n && col < len(row); col++ {
+		row[col] = Cell{Rune: ' '}
+	}
+}
+
+// defaultTabStops returns tab stops at every 8th column
+func defaultTabStops(width int) []bool {
+	stops := make([]bool, width)
+	for col := 8; col < width; col += 8 {
+		stops[col] = true
+	}
+	return stops
+}
+
+// nextTabStop returns the column of the n-th tab stop right of col, or left
+// of it for a negative n. It stops at the edges of the screen.
+func (b *Buffer) nextTabStop(col, n int) int {
+	for ; n > 0 && col < b.width-1; n-- {
+		col++
+		for col < b.width-1 && !b.tabStops[col] {
+			col++
+		}
+	}
+	for ; n < 0 && col > 0; n++ {
+		col--
+		for col > 0 && !b.tabStops[col] {
+			col--
+		}
+	}
+	return col
+}
+
+// SetScrollback limits the rows above the screen to limit. Older rows are
+// rendered and written to spill, one per line. A limit of 0 keeps all rows.
+func (b *Buffer) SetScrollback(limit int, spill io.Writer) {
+	b.scrollback = limit
+	b.spill = spill
+}
+
+// Truncated returns the number of rows that were dropped from the scrollback
+func (b *Buffer) Truncated() int {
+	return b.truncated
+}
+
+// trimScrollback drops the rows above the scrollback limit
+func (b *Buffer) trimScrollback() {
+	if b.scrollback <= 0 || b.top <= b.scrollback {
+		return
+	}
+	n := b.top - b.scrollback
+	// rows the screen scrolled past without writing to them are not allocated
+	allocated := min(n, len(b.cells))
+
+	if b.spill != nil {
+		var spilled strings.Builder
+		for i := 0; i < n; i++ {
+			switch {
+			case i < b.valid:
+				spilled.WriteString(b.lines[i])
+			case i < allocated:
+				spilled.WriteString(renderRow(b.cells[i]))
+			}
+			spilled.WriteByte('\n')
+		}
+		// rows that can't be written are lost
+		_, _ = io.WriteString(b.spill, spilled.String())
+	}
+
+	// the dropped rows are freed when the slices grow next time
+	for i := 0; i < allocated; i++ {
+		b.cells[i] = nil
+	}
+	b.cells = b.cells[allocated:]
+	skip := min(n, len(b.lines))
+	for i := 0; i < skip; i++ {
+		b.lines[i] = ""
+	}
+	b.lines = b.lines[skip:]
+	b.valid = max(0, b.valid-n)
+
+	b.top -= n
+	b.cursorRow -= n
+	b.last.row -= n
+	b.truncated += n
+	b.dirty = true
+}
+
+// primaryScreen keeps the main screen while the alternate screen is shown
+type primaryScreen struct {
+	cells     [][]Cell
+	top       int
+	cursorRow int
+	cursorCol int
+}
+
+// AltScreen reports whether the alternate screen is shown
+func (b *Buffer) AltScreen() bool {
+	return b.primary != nil
+}
+
+// enterAltScreen switches to an empty alternate screen. It has no
+// scrollback, the cursor stays on the same screen row.
+func (b *Buffer) enterAltScreen() {
+	if b.primary != nil {
+		return
+	}
+	b.primary = &primaryScreen{
+		cells:     b.cells,
+		top:       b.top,
+		cursorRow: b.cursorRow,
+		cursorCol: b.cursorCol,
+	}
+	b.cursorRow = b.screenRow()
+	b.cells = make([][]Cell, 0, b.height)
+	b.top = 0
+	b.switchScreen()
+}
+
+// leaveAltScreen switches back to the main screen
+func (b *Buffer) leaveAltScreen() {
+	if b.primary == nil {
+		return
+	}
+	primary := b.primary
+	b.primary = nil
+	b.cells = primary.cells
+	b.top = primary.top
+	b.setCursor(primary.cursorRow, primary.cursorCol)
+	b.switchScreen()
+}
+
+// switchScreen forgets the state that belongs to the screen that was shown
+func (b *Buffer) switchScreen() {
+	b.last = lastCell{}
+	b.wrapNext = false
+	b.valid = 0
+	b.dirty = true
+}
+
+// Resize changes the size of the screen. The cursor stays on the screen, on
+// the main screen the rows above it go to the scrollback, on the alternate
+// screen they are dropped. Rows below the screen are dropped.
+func (b *Buffer) Resize(width, height int) {
+	width = max(1, width)
+	height = max(1, height)
+	if width == b.width && height == b.height {
+		return
+	}
+
+	if row := b.screenRow(); row >= height {
+		b.top += row - height + 1
+	}
+	if b.primary != nil && b.top > 0 {
+		b.cells = b.cells[min(b.top, len(b.cells)):]
+		b.cursorRow -= b.top
+		b.last.row -= b.top
+		b.top = 0
+	}
+	if len(b.cells) > b.top+height {
+		b.cells = b.cells[:b.top+height]
+	}
+	if b.primary != nil {
+		for i, row := range b.cells {
+			if len(row) > width {
+				b.cells[i] = row[:width]
+			}
+		}
+	}
+
+	b.width = width
+	b.height = height
+	b.scrollTop = 0
+	b.scrollBottom = height - 1
+	b.tabStops = defaultTabStops(width)
+	b.setCursor(b.cursorRow, b.cursorCol)
+	b.valid = min(b.valid, b.top)
+	b.dirty = true
+	b.trimScrollback()
+}
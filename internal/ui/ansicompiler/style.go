@@ -0,0 +1,276 @@
+package ansicompiler
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ColorKind tells how a color was specified
+type ColorKind uint8
+
+const (
+	// ColorDefault is the default color of the terminal
+	ColorDefault ColorKind = iota
+	// ColorBasic is one of the 16 colors set by SGR 30-37, 90-97 and their
+	// background counterparts
+	ColorBasic
+	// ColorIndexed is a color of the 256 color palette
+	ColorIndexed
+	// ColorRGB is a true color
+	ColorRGB
+)
+
+// Color is a foreground, background or underline color
+type Color struct {
+	Kind ColorKind
+	// Index is the palette index of basic and indexed colors
+	Index uint8
+	// R, G and B are the components of true colors
+	R, G, B uint8
+}
+
+// Attr is a set of text attributes
+type Attr uint16
+
+const (
+	AttrBold Attr = 1 << iota
+	AttrDim
+	AttrItalic
+	AttrBlink
+	AttrReverse
+	AttrHidden
+	AttrStrike
+	AttrOverline
+)
+
+// UnderlineStyle is the style of the underline, set with SGR 4:n
+type UnderlineStyle uint8
+
+const (
+	UnderlineNone UnderlineStyle = iota
+	UnderlineSingle
+	UnderlineDouble
+	UnderlineCurly
+	UnderlineDotted
+	UnderlineDashed
+)
+
+// Style is the graphic rendition of a cell. The zero value is the default
+// style of the terminal.
+type Style struct {
+	Fg             Color
+	Bg             Color
+	UnderlineColor Color
+	Attrs          Attr
+	Underline      UnderlineStyle
+}
+
+// attrCodes are the SGR codes that turn attributes on and off, in the order
+// they are emitted
+var attrCodes = []struct {
+	attr Attr
+	on   string
+	off  string
+}{
+	{AttrBold, "1", "22"},
+	{AttrDim, "2", "22"},
+	{AttrItalic, "3", "23"},
+	{AttrBlink, "5", "25"},
+	{AttrReverse, "7", "27"},
+	{AttrHidden, "8", "28"},
+	{AttrStrike, "9", "29"},
+	{AttrOverline, "53", "55"},
+}
+
+// apply changes the style according to the parameters of an SGR sequence
+func (s *Style) apply(params [][]int) {
+	if len(params) == 0 {
+		*s = Style{}
+		return
+	}
+
+	for i := 0; i < len(params); i++ {
+		param := params[i]
+		code := param[0]
+		switch {
+		case code == MissingParam, code == 0:
+			*s = Style{}
+		case code == 1:
+			s.Attrs |= AttrBold
+		case code == 2:
+			s.Attrs |= AttrDim
+		case code == 3:
+			s.Attrs |= AttrItalic
+		case code == 4:
+			s.Underline = UnderlineSingle
+			if len(param) > 1 && param[1] >= 0 && param[1] <= int(UnderlineDashed) {
+				s.Underline = UnderlineStyle(param[1])
+			}
+		case code == 5, code == 6:
+			s.Attrs |= AttrBlink
+		case code == 7:
+			s.Attrs |= AttrReverse
+		case code == 8:
+			s.Attrs |= AttrHidden
+		case code == 9:
+			s.Attrs |= AttrStrike
+		case code == 21:
+			s.Underline = UnderlineDouble
+		case code == 22:
+			s.Attrs &^= AttrBold | AttrDim
+		case code == 23:
+			s.Attrs &^= AttrItalic
+		case code == 24:
+			s.Underline = UnderlineNone
+		case code == 25:
+			s.Attrs &^= AttrBlink
+		case code == 27:
+			s.Attrs &^= AttrReverse
+		case code == 28:
+			s.Attrs &^= AttrHidden
+		case code == 29:
+			s.Attrs &^= AttrStrike
+		case code >= 30 && code <= 37:
+			s.Fg = Color{Kind: ColorBasic, Index: uint8(code - 30)}
+		case code == 38:
+			s.Fg, i = extendedColor(params, i)
+		case code == 39:
+			s.Fg = Color{}
+		case code >= 40 && code <= 47:
+			s.Bg = Color{Kind: ColorBasic, Index: uint8(code - 40)}
+		case code == 48:
+			s.Bg, i = extendedColor(params, i)
+		case code == 49:
+			s.Bg = Color{}
+		case code == 53:
+			s.Attrs |= AttrOverline
+		case code == 55:
+			s.Attrs &^= AttrOverline
+		case code == 58:
+			s.UnderlineColor, i = extendedColor(params, i)
+		case code == 59:
+			s.UnderlineColor = Color{}
+		case code >= 90 && code <= 97:
+			s.Fg = Color{Kind: ColorBasic, Index: uint8(code - 90 + 8)}
+		case code >= 100 && code <= 107:
+			s.Bg = Color{Kind: ColorBasic, Index: uint8(code - 100 + 8)}
+		}
+	}
+}
+
+// extendedColor parses the color of SGR 38, 48 and 58 starting at params[i].
+// It accepts the colon form (38:5:n, 38:2::r:g:b, 38:2:r:g:b) and the
+// semicolon form (38;5;n, 38;2;r;g;b) and returns the index of the last
+// parameter it used.
+func extendedColor(params [][]int, i int) (Color, int) {
+	var args []int
+	if len(params[i]) > 1 {
+		args = params[i][1:]
+		// the colon form has an optional color space id before r:g:b
+		if len(args) == 5 && args[0] == 2 {
+			args = append([]int{2}, args[2:]...)
+		}
+	} else {
+		for _, p := range params[i+1:] {
+			args = append(args, p[0])
+		}
+	}
+
+	component := func(v int) uint8 {
+		return uint8(min(max(v, 0), 255))
+	}
+
+	switch {
+	case len(args) >= 2 && args[0] == 5:
+		if len(params[i]) == 1 {
+			i += 2
+		}
+		return Color{Kind: ColorIndexed, Index: component(args[1])}, i
+	case len(args) >= 4 && args[0] == 2:
+		if len(params[i]) == 1 {
+			i += 4
+		}
+		return Color{Kind: ColorRGB, R: component(args[1]), G: component(args[2]), B: component(args[3])}, i
+	}
+	// malformed, skip the rest of the sequence like xterm does
+	return Color{}, len(params)
+}
+
+// codes returns the SGR parameters that select c, base is 30 for the
+// foreground, 40 for the background and 50 for the underline
+func (c Color) codes(base int) string {
+	switch c.Kind {
+	case ColorBasic:
+		if base == 50 {
+			return "58;5;" + strconv.Itoa(int(c.Index))
+		}
+		if c.Index >= 8 {
+			return strconv.Itoa(base + 60 + int(c.Index) - 8)
+		}
+		return strconv.Itoa(base + int(c.Index))
+	case ColorIndexed:
+		return strconv.Itoa(base+8) + ";5;" + strconv.Itoa(int(c.Index))
+	case ColorRGB:
+		return strconv.Itoa(base+8) + ";2;" + strconv.Itoa(int(c.R)) + ";" + strconv.Itoa(int(c.G)) + ";" + strconv.Itoa(int(c.B))
+	default:
+		return strconv.Itoa(base + 9)
+	}
+}
+
+// code returns the SGR parameter that selects the underline style
+func (u UnderlineStyle) code() string {
+	switch u {
+	case UnderlineNone:
+		return "24"
+	case UnderlineSingle:
+		return "4"
+	default:
+		return "4:" + strconv.Itoa(int(u))
+	}
+}
+
+// diff returns the shortest SGR sequence that changes prev to s
+func (s Style) diff(prev Style) string {
+	if s == prev {
+		return ""
+	}
+	if s == (Style{}) {
+		return "\x1b[0m"
+	}
+
+	var codes []string
+
+	removed := prev.Attrs &^ s.Attrs
+	added := s.Attrs &^ prev.Attrs
+	if removed&(AttrBold|AttrDim) != 0 {
+		// 22 turns off both bold and dim, the one that stays is turned on again
+		codes = append(codes, "22")
+		removed &^= AttrBold | AttrDim
+		added |= s.Attrs & (AttrBold | AttrDim)
+	}
+	for _, a := range attrCodes {
+		if removed&a.attr != 0 {
+			codes = append(codes, a.off)
+		}
+	}
+	for _, a := range attrCodes {
+		if added&a.attr != 0 {
+			codes = append(codes, a.on)
+		}
+	}
+
+	if s.Underline != prev.Underline {
+		codes = append(codes, s.Underline.code())
+	}
+	if s.Fg != prev.Fg {
+		codes = append(codes, s.Fg.codes(30))
+	}
+	if s.Bg != prev.Bg {
+		codes = append(codes, s.Bg.codes(40))
+	}
+	if s.UnderlineColor != prev.UnderlineColor {
+		codes = append(codes, s.UnderlineColor.codes(50))
+	}
+
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
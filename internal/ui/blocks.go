@@ -0,0 +1,235 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+	"github.com/tsukinoko-kun/ohmygosh/internal/export"
+)
+
+// textItem is a static text in the list of blocks, like the neofetch banner.
+type textItem string
+
+func (t textItem) Height() int {
+	return strings.Count(string(t), "\n") + 1
+}
+
+func (t textItem) View(from, to int) string {
+	return strings.Join(strings.Split(string(t), "\n")[from:to], "\n")
+}
+
+// blockItem is a command block in the list of blocks. It is a snapshot of the
+// block taken by updateViewContent, only the lines in view are styled.
+type blockItem struct {
+	block  *CommandBlock
+	style  lipgloss.Style
+	header []string
+	// body are the lines between the header and the output
+	body   []string
+	output []string
+}
+
+// newBlockItem takes a snapshot of block. The caller must hold block.mu.
+func newBlockItem(block *CommandBlock) blockItem {
+	key := newHeaderKey(block)
+	if block.header == nil || block.header.key != key {
+		block.header = renderHeader(block, key)
+	}
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color(config.Get.Ui.HeaderColor))
+
+	item := blockItem{
+		block:  block,
+		style:  block.header.style,
+		header: block.header.lines,
+	}
+	if block.InDirectMode {
+		// Show a placeholder for blocks running in direct mode
+		item.body = []string{"[Running in full-screen mode - press any key to return when finished]"}
+		return item
+	}
+	screen := block.compile()
+	if block.Collapsed {
+		item.body = []string{headerStyle.Render(fmt.Sprintf("[%d lines collapsed]", screen.Rows()))}
+		return item
+	}
+	if marker := truncatedMarker(block); marker != "" && !block.Embedded {
+		item.body = []string{headerStyle.Render(marker)}
+	}
+	item.output = block.Screen.Lines()
+	if block.Embedded && block.Focused {
+		// Draw the cursor of the program
+		if row, line, ok := block.Screen.CursorLine(); ok && row < len(item.output) {
+			item.output = slices.Clone(item.output)
+			item.output[row] = line
+		}
+	}
+	if len(item.output) == 0 {
+		item.output = []string{""}
+	}
+	return item
+}
+
+// blockHeader is the rendered header of a block and the style of the block.
+// It is kept by the block and only rendered again when its key changes, so
+// blocks that didn't change cost little on every update of the view.
+type blockHeader struct {
+	key   headerKey
+	style lipgloss.Style
+	lines []string
+}
+
+// headerKey is the state of a block the header depends on.
+type headerKey struct {
+	prompt     string
+	command    string
+	copyError  string
+	copyStatus CopyStatus
+	exitCode   int
+	duration   time.Duration
+	running    bool
+	stopped    bool
+	focused    bool
+	selected   bool
+}
+
+func newHeaderKey(block *CommandBlock) headerKey {
+	return headerKey{
+		prompt:     block.Prompt,
+		command:    block.Command,
+		copyError:  block.CopyError,
+		copyStatus: block.CopyStatus,
+		exitCode:   block.ExitCode,
+		duration:   block.EndTime.Sub(block.StartTime),
+		running:    block.IsRunning,
+		stopped:    block.Stopped,
+		focused:    block.Focused,
+		selected:   block.Selected,
+	}
+}
+
+// renderHeader renders the header of block in the state key.
+func renderHeader(block *CommandBlock, key headerKey) *blockHeader {
+	// Style definitions
+	blockStyle := lipgloss.NewStyle().
+		BorderStyle(lipgloss.ThickBorder()).
+		BorderForeground(lipgloss.Color(config.Get.Ui.BorderColor)).
+		BorderLeft(true)
+
+	focusedBlockStyle := blockStyle.
+		BorderStyle(lipgloss.ThickBorder()).
+		BorderRight(false).
+		BorderLeft(true).
+		BorderTop(false).
+		BorderBottom(false).
+		BorderForeground(lipgloss.Color(config.Get.Ui.BorderColorFocus))
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color(config.Get.Ui.HeaderColor))
+
+	runningStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color(config.Get.Ui.RunningColor))
+
+	completedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color(config.Get.Ui.CompletedColor))
+
+	failedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color(config.Get.Ui.FailedColor))
+
+	selectedBlockStyle := focusedBlockStyle.
+		BorderStyle(lipgloss.DoubleBorder()).
+		BorderForeground(lipgloss.Color(config.Get.Ui.BorderColorSelected))
+
+	// Choose appropriate styles
+	style := blockStyle
+	if block.Focused {
+		style = focusedBlockStyle
+	}
+	if block.Selected {
+		style = selectedBlockStyle
+	}
+
+	headerCommandStyle := lipgloss.NewStyle()
+
+	// Create status indicator
+	var statusStr string
+	if block.IsRunning {
+		statusStr = zone.Mark(fmt.Sprintf("block_cancel_%d", block.ID), runningStyle.Render(""))
+		headerCommandStyle = headerCommandStyle.Foreground(lipgloss.Color(config.Get.Ui.HeaderCommandColorRunning))
+		if block.Stopped {
+			statusStr += runningStyle.Render(fmt.Sprintf(" [%d] Stopped", block.ID))
+		}
+	} else {
+		if block.ExitCode != 0 {
+			headerCommandStyle = headerCommandStyle.Foreground(lipgloss.Color(config.Get.Ui.HeaderCommandColorFailed))
+			statusStr = failedStyle.Render(fmt.Sprintf("✗ %d", block.ExitCode))
+		} else {
+			headerCommandStyle = headerCommandStyle.Foreground(lipgloss.Color(config.Get.Ui.HeaderCommandColorDone))
+			duration := export.RoundDuration(block.EndTime.Sub(block.StartTime))
+			statusStr = completedStyle.Render(fmt.Sprintf("✓ (%s)", duration))
+		}
+	}
+
+	if block.IsRunning {
+		headerCommandStyle = headerCommandStyle.Foreground(lipgloss.Color("7"))
+	}
+
+	copyStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(config.Get.Ui.HeaderCopyColor))
+	copyBtn := copyButtons(block, copyStyle)
+	switch block.CopyStatus {
+	case CopyStatusSuccess:
+		copyBtn += copyStyle.Render(" ✓")
+	case CopyStatusFailure:
+		copyBtn += copyStyle.Render(" ✗ " + block.CopyError)
+	}
+
+	// Format header with command and status
+	header := headerStyle.Render(fmt.Sprintf("%s %s\n%s %s", block.Prompt, copyBtn, statusStr, headerCommandStyle.Render(block.Command)))
+	return &blockHeader{key: key, style: style, lines: strings.Split(header, "\n")}
+}
+
+func (b blockItem) Height() int {
+	// the header is followed by an empty line, the block by a margin
+	return len(b.header) + 1 + len(b.body) + len(b.output) + 1
+}
+
+func (b blockItem) View(from, to int) string {
+	margin := b.Height() - 1
+	lines := make([]string, 0, to-from)
+	for i := from; i < min(to, margin); i++ {
+		lines = append(lines, b.line(i))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	view := b.style.Render(strings.Join(lines, "\n"))
+	if to > margin {
+		view += "\n"
+	}
+	return view
+}
+
+// line returns the unstyled line i of the block.
+func (b blockItem) line(i int) string {
+	switch {
+	case i < len(b.header):
+		return b.header[i]
+	case i == len(b.header):
+		return ""
+	}
+	i -= len(b.header) + 1
+	if i < len(b.body) {
+		return b.body[i]
+	}
+	i -= len(b.body)
+	if i < len(b.output) {
+		return outputLine(b.block, i, b.output[i])
+	}
+	return ""
+}
@@ -3,10 +3,11 @@ package vimtextinput
 import (
 	"strings"
 	"time"
+	"unicode"
 
-	"github.com/atotto/clipboard"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/tsukinoko-kun/ohmygosh/internal/clipboard"
 	"github.com/tsukinoko-kun/ohmygosh/internal/config"
 	"github.com/tsukinoko-kun/ohmygosh/internal/history"
 	"github.com/tsukinoko-kun/ohmygosh/internal/prompt"
@@ -133,6 +134,13 @@ func (m Model) handleKeyMsg(msg tea.KeyMsg) (Model, tea.Cmd) {
 		m.cursor = len(m.value)
 	}
 
+	// Pastes carry the pasted text, they are inserted in every mode
+	if msg.Paste {
+		m.InsertText(string(msg.Runes))
+		history.SetFilter(m.value)
+		return m, nil
+	}
+
 	switch msg.String() {
 	case "up":
 		newValue := history.Peek()
@@ -228,14 +236,14 @@ func (m Model) handleNormalMode(msg tea.KeyMsg) (Model, tea.Cmd) {
 		m.mode = ModeVisual
 		m.visualStart = m.cursor
 	case "y":
-		_ = clipboard.WriteAll(m.value)
+		_ = clipboard.Write(m.value)
 	case "p":
-		text, err := clipboard.ReadAll()
+		text, err := clipboard.Read()
 		if err == nil {
 			m.InsertText(text)
 		}
 	case "P":
-		text, err := clipboard.ReadAll()
+		text, err := clipboard.Read()
 		if err == nil {
 			m.cursor--
 			if m.cursor < 0 {
@@ -276,12 +284,6 @@ func (m Model) handleNormalMode(msg tea.KeyMsg) (Model, tea.Cmd) {
 
 // handleInsertMode handles keys in insert mode
 func (m Model) handleInsertMode(msg tea.KeyMsg) (Model, tea.Cmd) {
-	if msg.Paste {
-		cb, _ := clipboard.ReadAll()
-		m.InsertText(cb)
-		return m, nil
-	}
-
 	switch msg.String() {
 	case "esc":
 		m.mode = ModeNormal
@@ -329,13 +331,13 @@ func (m Model) handleVisualMode(msg tea.KeyMsg) (Model, tea.Cmd) {
 	case "y":
 		start, end := m.getVisualSelection()
 		if start != end {
-			_ = clipboard.WriteAll(m.value[start:end])
+			_ = clipboard.Write(m.value[start:end])
 		}
 		m.mode = ModeNormal
 	case "d", "x":
 		start, end := m.getVisualSelection()
 		if start != end {
-			_ = clipboard.WriteAll(m.value[start:end])
+			_ = clipboard.Write(m.value[start:end])
 			m.value = m.value[:start] + m.value[end:]
 			m.cursor = start
 		}
@@ -476,7 +478,7 @@ func (m *Model) moveWordEnd() {
 
 // Text manipulation functions
 func (m *Model) InsertText(text string) {
-	text = strings.ReplaceAll(text, "\n", " ")
+	text = sanitize(text)
 	if m.cursor >= len(m.value)-1 {
 		m.value += text
 		m.cursor = len(m.value)
@@ -489,6 +491,21 @@ func (m *Model) InsertText(text string) {
 	}
 }
 
+// sanitize prepares text for the prompt. Line breaks are kept so a pasted
+// script runs as it is, they never submit the prompt on their own. Other
+// control characters, like escape sequences, are removed.
+func sanitize(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.TrimRight(text, "\n")
+	return strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
 func (m *Model) deleteChar() {
 	if m.cursor < len(m.value) {
 		m.value = m.value[:m.cursor] + m.value[m.cursor+1:]
@@ -554,7 +571,15 @@ func (m Model) View() string {
 
 	// Render text with cursor
 	for i, char := range m.value {
-		charStr := string(char)
+		var charStr string
+		switch char {
+		case '\n':
+			charStr = "↵"
+		case '\t':
+			charStr = " "
+		default:
+			charStr = string(char)
+		}
 
 		if i == m.cursor && m.focused {
 			b.WriteString(m.textStyle.Background(lipgloss.Color(config.Get.Ui.CursorColor)).Foreground(lipgloss.Color("0")).Render(charStr))
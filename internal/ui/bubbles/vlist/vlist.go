@@ -0,0 +1,228 @@
+// Package vlist is a viewport for a list of items of different heights that
+// only renders the items in view.
+package vlist
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Item is an entry of the list.
+type Item interface {
+	// Height returns the number of lines View returns, it is called often and
+	// should be cheap.
+	Height() int
+	// View renders the lines from to to (exclusive) of the item, it is only
+	// called for the lines in view.
+	View(from, to int) string
+}
+
+// Model is the state of the list.
+// The position is kept as a line of an item instead of a line of the whole
+// content, so it doesn't move when items above it grow. While the list
+// follows its end, new content scrolls into view.
+type Model struct {
+	Width  int
+	Height int
+	// MouseWheelDelta is the number of lines a step of the mouse wheel scrolls.
+	MouseWheelDelta int
+
+	items []Item
+	// the view starts offset lines into the item at index anchor
+	anchor int
+	offset int
+	follow bool
+}
+
+// New returns a list of the given size that follows its end.
+func New(width, height int) Model {
+	return Model{
+		Width:           width,
+		Height:          height,
+		MouseWheelDelta: 3,
+		follow:          true,
+	}
+}
+
+// SetItems replaces the items. The position stays on the same item.
+func (m *Model) SetItems(items []Item) {
+	m.items = items
+	m.clamp()
+}
+
+// AtBottom reports whether the end of the list is in view.
+func (m *Model) AtBottom() bool {
+	if m.follow {
+		return true
+	}
+	lines := -m.offset
+	for _, item := range m.items[m.anchor:] {
+		lines += item.Height()
+		if lines > m.Height {
+			return false
+		}
+	}
+	return true
+}
+
+// GotoTop scrolls to the start of the list.
+func (m *Model) GotoTop() {
+	m.follow = false
+	m.anchor = 0
+	m.offset = 0
+	m.clamp()
+}
+
+// GotoBottom scrolls to the end of the list and follows it from now on.
+func (m *Model) GotoBottom() {
+	m.follow = true
+}
+
+// ScrollUp moves the view n lines up.
+func (m *Model) ScrollUp(n int) {
+	if m.follow {
+		m.anchor, m.offset = m.bottom()
+		m.follow = false
+	}
+	m.offset -= n
+	for m.offset < 0 && m.anchor > 0 {
+		m.anchor--
+		m.offset += m.items[m.anchor].Height()
+	}
+	m.offset = max(m.offset, 0)
+}
+
+// ScrollDown moves the view n lines down. It follows the end of the list
+// again once it is reached.
+func (m *Model) ScrollDown(n int) {
+	if m.follow {
+		return
+	}
+	m.offset += n
+	m.clamp()
+}
+
+// ShowItem scrolls as little as needed to bring the item at index i into view.
+// Items taller than the list are shown from their start.
+func (m *Model) ShowItem(i int) {
+	if i < 0 || i >= len(m.items) {
+		return
+	}
+	anchor, offset := m.anchor, m.offset
+	if m.follow {
+		anchor, offset = m.bottom()
+	}
+
+	// the first line of the item relative to the top of the view
+	start := -offset
+	for j := anchor; j < i; j++ {
+		start += m.items[j].Height()
+	}
+	for j := i; j < anchor; j++ {
+		start -= m.items[j].Height()
+	}
+	height := m.items[i].Height()
+
+	switch {
+	case start < 0 || height > m.Height:
+		m.follow = false
+		m.anchor, m.offset = i, 0
+		m.clamp()
+	case start+height > m.Height:
+		m.follow = false
+		m.anchor, m.offset = anchor, offset
+		m.ScrollDown(start + height - m.Height)
+	}
+}
+
+// clamp moves the position to the start of the item it is in and follows the
+// end of the list if it is reached.
+func (m *Model) clamp() {
+	if m.follow {
+		return
+	}
+	if m.anchor >= len(m.items) {
+		m.follow = true
+		return
+	}
+	for m.anchor < len(m.items)-1 && m.offset >= m.items[m.anchor].Height() {
+		m.offset -= m.items[m.anchor].Height()
+		m.anchor++
+	}
+	if m.AtBottom() {
+		m.follow = true
+	}
+}
+
+// bottom returns the position that shows the end of the list.
+func (m *Model) bottom() (anchor, offset int) {
+	lines := 0
+	for i := len(m.items) - 1; i >= 0; i-- {
+		lines += m.items[i].Height()
+		if lines >= m.Height {
+			return i, lines - m.Height
+		}
+	}
+	return 0, 0
+}
+
+// Update scrolls the list for mouse wheel events and page keys.
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.MouseMsg:
+		if msg.Action != tea.MouseActionPress {
+			break
+		}
+		switch msg.Button {
+		case tea.MouseButtonWheelUp:
+			m.ScrollUp(m.MouseWheelDelta)
+		case tea.MouseButtonWheelDown:
+			m.ScrollDown(m.MouseWheelDelta)
+		}
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "pgup":
+			m.ScrollUp(max(1, m.Height-1))
+		case "pgdown":
+			m.ScrollDown(max(1, m.Height-1))
+		case "home":
+			m.GotoTop()
+		case "end":
+			m.GotoBottom()
+		}
+	}
+	return m, nil
+}
+
+// View renders the items in view, lines wider than the list are cut.
+func (m Model) View() string {
+	anchor, offset := m.anchor, m.offset
+	if m.follow {
+		anchor, offset = m.bottom()
+	}
+
+	lines := make([]string, 0, m.Height)
+	for _, item := range m.items[min(anchor, len(m.items)):] {
+		if len(lines) >= m.Height {
+			break
+		}
+		from := min(offset, item.Height())
+		to := min(item.Height(), from+m.Height-len(lines))
+		offset = 0
+		if from == to {
+			continue
+		}
+		for _, line := range strings.Split(item.View(from, to), "\n") {
+			lines = append(lines, ansi.Truncate(line, m.Width, ""))
+		}
+	}
+	lines = lines[:min(len(lines), m.Height)]
+
+	// fill the height, so whatever follows the list stays in place
+	for len(lines) < m.Height {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,209 @@
+package vlist_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/ui/bubbles/vlist"
+)
+
+// item is a list item with lines named after the item and the line index.
+type item struct {
+	name   string
+	height int
+}
+
+func (i *item) Height() int {
+	return i.height
+}
+
+func (i *item) View(from, to int) string {
+	lines := make([]string, 0, to-from)
+	for l := from; l < to; l++ {
+		lines = append(lines, fmt.Sprintf("%s%d", i.name, l))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func newList(height int, items ...*item) vlist.Model {
+	m := vlist.New(10, height)
+	list := make([]vlist.Item, len(items))
+	for i, it := range items {
+		list[i] = it
+	}
+	m.SetItems(list)
+	return m
+}
+
+func view(lines ...string) string {
+	return strings.Join(lines, "\n")
+}
+
+func TestScroll(t *testing.T) {
+	tests := []struct {
+		name string
+		move func(m *vlist.Model)
+		want string
+	}{
+		{
+			name: "follows the end",
+			move: func(m *vlist.Model) {},
+			want: view("c1", "c2", "c3"),
+		},
+		{
+			name: "up within an item",
+			move: func(m *vlist.Model) { m.ScrollUp(1) },
+			want: view("c0", "c1", "c2"),
+		},
+		{
+			name: "up across an item",
+			move: func(m *vlist.Model) { m.ScrollUp(3) },
+			want: view("b0", "b1", "c0"),
+		},
+		{
+			name: "up past the start",
+			move: func(m *vlist.Model) { m.ScrollUp(100) },
+			want: view("a0", "a1", "a2"),
+		},
+		{
+			name: "down across an item",
+			move: func(m *vlist.Model) { m.GotoTop(); m.ScrollDown(4) },
+			want: view("b1", "c0", "c1"),
+		},
+		{
+			name: "down past the end",
+			move: func(m *vlist.Model) { m.GotoTop(); m.ScrollDown(100) },
+			want: view("c1", "c2", "c3"),
+		},
+		{
+			name: "up and back down",
+			move: func(m *vlist.Model) { m.ScrollUp(2); m.ScrollDown(2) },
+			want: view("c1", "c2", "c3"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newList(3, &item{"a", 3}, &item{"b", 2}, &item{"c", 4})
+			tt.move(&m)
+			if got := m.View(); got != tt.want {
+				t.Errorf("View() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrollDownFollows(t *testing.T) {
+	a, b := &item{"a", 3}, &item{"b", 2}
+	m := newList(3, a, b)
+	m.ScrollUp(2)
+	if m.AtBottom() {
+		t.Fatal("AtBottom() after ScrollUp")
+	}
+	m.ScrollDown(2)
+	if !m.AtBottom() {
+		t.Fatal("!AtBottom() after scrolling back down")
+	}
+
+	// new output scrolls into view
+	b.height = 4
+	m.SetItems([]vlist.Item{a, b})
+	if got, want := m.View(), view("b1", "b2", "b3"); got != want {
+		t.Errorf("View() = %q, want %q", got, want)
+	}
+}
+
+func TestGrow(t *testing.T) {
+	tests := []struct {
+		name string
+		grow func(a, b *item)
+		want string
+	}{
+		{
+			name: "item in view",
+			grow: func(a, b *item) { a.height = 6 },
+			want: view("a1", "a2", "a3"),
+		},
+		{
+			name: "item below",
+			grow: func(a, b *item) { b.height = 5 },
+			want: view("a1", "a2", "b0"),
+		},
+		{
+			name: "item shrinks below the position",
+			grow: func(a, b *item) { a.height = 1 },
+			want: view("a0", "b0", "b1"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := &item{"a", 3}, &item{"b", 2}
+			m := newList(3, a, b)
+			m.ScrollUp(1)
+			if got, want := m.View(), view("a1", "a2", "b0"); got != want {
+				t.Fatalf("View() = %q, want %q", got, want)
+			}
+
+			tt.grow(a, b)
+			m.SetItems([]vlist.Item{a, b})
+			if got := m.View(); got != tt.want {
+				t.Errorf("View() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShowItem(t *testing.T) {
+	tests := []struct {
+		name  string
+		move  func(m *vlist.Model)
+		index int
+		want  string
+	}{
+		{
+			name:  "item in view",
+			index: 2,
+			want:  view("b4", "c0", "c1"),
+		},
+		{
+			name:  "tall item from its start",
+			index: 1,
+			want:  view("b0", "b1", "b2"),
+		},
+		{
+			name:  "tall item already at the top",
+			move:  func(m *vlist.Model) { m.ShowItem(1) },
+			index: 1,
+			want:  view("b0", "b1", "b2"),
+		},
+		{
+			name:  "item above",
+			index: 0,
+			want:  view("a0", "a1", "b0"),
+		},
+		{
+			name:  "item below",
+			move:  func(m *vlist.Model) { m.GotoTop() },
+			index: 2,
+			want:  view("b4", "c0", "c1"),
+		},
+		{
+			name:  "index out of range",
+			move:  func(m *vlist.Model) { m.GotoTop() },
+			index: 3,
+			want:  view("a0", "a1", "b0"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newList(3, &item{"a", 2}, &item{"b", 5}, &item{"c", 2})
+			if tt.move != nil {
+				tt.move(&m)
+			}
+			m.ShowItem(tt.index)
+			if got := m.View(); got != tt.want {
+				t.Errorf("View() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package ui
+
+import (
+	"time"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/builtins"
+	"github.com/tsukinoko-kun/ohmygosh/internal/clipboard"
+	"github.com/tsukinoko-kun/ohmygosh/internal/prompt"
+)
+
+// ExecuteBuiltin runs a builtin in-process and returns the finished block.
+func (m *Model) ExecuteBuiltin(cmd string, id int, b builtins.Builtin, args []string) *CommandBlock {
+	block := &CommandBlock{
+		ID:        id,
+		Command:   cmd,
+		Prompt:    prompt.Get(),
+		StartTime: time.Now(),
+	}
+
+	ctx := &builtins.Context{
+		Stdout:     &block.Output,
+		Stderr:     &block.Output,
+		Jobs:       m.jobs,
+		Foreground: m.foreground,
+		Background: m.background,
+		Cast:       m.castBlocks,
+		Export:     m.exportBlocks,
+		Clipboard:  clipboard.Write,
+	}
+	block.ExitCode = b(ctx, args)
+	block.EndTime = time.Now()
+
+	return block
+}
@@ -0,0 +1,127 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/tsukinoko-kun/ohmygosh/internal/clipboard"
+	"github.com/tsukinoko-kun/ohmygosh/internal/export"
+)
+
+// copyAction is a way to copy a block, reachable by a button in the header
+// of the block and a key.
+type copyAction struct {
+	// name is part of the zone id of the button
+	name  string
+	label string
+	key   string
+	// text returns what is copied. The caller must hold block.mu.
+	text func(block *CommandBlock) (string, error)
+}
+
+var copyActions = []copyAction{
+	{name: "block", label: "", key: "alt+y", text: copyFullBlock},
+	{name: "cmd", label: "cmd", key: "alt+c", text: copyCommand},
+	{name: "out", label: "out", key: "alt+o", text: copyOutput},
+	{name: "ansi", label: "ansi", key: "alt+a", text: copyOutputANSI},
+}
+
+// copyFullBlock returns the prompt, command, output and status of a block as
+// plain text.
+func copyFullBlock(block *CommandBlock) (string, error) {
+	b, err := exportBlock(block)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := export.Write(&out, export.Text, []export.Block{b}); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func copyCommand(block *CommandBlock) (string, error) {
+	return block.Command, nil
+}
+
+func copyOutput(block *CommandBlock) (string, error) {
+	output, err := block.fullOutput()
+	if err != nil {
+		return "", err
+	}
+	return export.PlainText(output), nil
+}
+
+func copyOutputANSI(block *CommandBlock) (string, error) {
+	return block.fullOutput()
+}
+
+// zoneID returns the id of the button of the action for block.
+func (a copyAction) zoneID(block *CommandBlock) string {
+	return fmt.Sprintf("block_copy_%s_%d", a.name, block.ID)
+}
+
+// copyButtons renders the buttons of all copy actions of block.
+func copyButtons(block *CommandBlock, style lipgloss.Style) string {
+	buttons := make([]string, len(copyActions))
+	for i, action := range copyActions {
+		buttons[i] = zone.Mark(action.zoneID(block), style.Render(action.label))
+	}
+	return strings.Join(buttons, " ")
+}
+
+// copyBlock puts the text of action for block on the clipboard and shows the
+// result in the header of the block.
+func (m *Model) copyBlock(block *CommandBlock, action copyAction) {
+	block.mu.Lock()
+	text, err := action.text(block)
+	block.mu.Unlock()
+	if err == nil {
+		err = clipboard.Write(text)
+	}
+	if err != nil {
+		block.CopyStatus = CopyStatusFailure
+		block.CopyError = err.Error()
+	} else {
+		block.CopyStatus = CopyStatusSuccess
+	}
+	m.updateViewContent()
+}
+
+// copyActionAt returns the block and the copy action of the button under the
+// mouse.
+func (m *Model) copyActionAt(msg tea.MouseMsg) (*CommandBlock, copyAction, bool) {
+	for _, block := range m.Commands {
+		for _, action := range copyActions {
+			if zone.Get(action.zoneID(block)).InBounds(msg) {
+				return block, action, true
+			}
+		}
+	}
+	return nil, copyAction{}, false
+}
+
+// copyActionForKey returns the copy action bound to key.
+func copyActionForKey(key string) (copyAction, bool) {
+	for _, action := range copyActions {
+		if action.key == key {
+			return action, true
+		}
+	}
+	return copyAction{}, false
+}
+
+// copyTarget returns the block copy keys apply to outside of the navigation
+// mode, the focused block or otherwise the last one.
+func (m *Model) copyTarget() *CommandBlock {
+	if m.FocusedBlock != nil {
+		return m.FocusedBlock
+	}
+	if len(m.Commands) == 0 {
+		return nil
+	}
+	return m.Commands[len(m.Commands)-1]
+}
@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/commands"
+	"github.com/tsukinoko-kun/ohmygosh/internal/data"
+)
+
+// Files with the output of blocks are kept in a directory per process below
+// data.Path, only the user can read them. The directory is removed when
+// ohmygosh exits, the directories of processes that are gone (crashed or
+// killed) are removed on the next start.
+
+const (
+	scrollbackDir = "scrollback"
+	castsDir      = "casts"
+)
+
+// processDir returns the directory of this process for files of the kind
+// name, it is created if it doesn't exist.
+func processDir(name string) (string, error) {
+	parent := filepath.Join(data.Path, name)
+	if err := os.MkdirAll(parent, 0700); err != nil {
+		return "", err
+	}
+	// older versions created it readable by everyone
+	if err := os.Chmod(parent, 0700); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(parent, strconv.Itoa(os.Getpid()))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// removeStale deletes the files of the kind name that belong to no running
+// process.
+func removeStale(name string) {
+	parent := filepath.Join(data.Path, name)
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if pid, err := strconv.Atoi(entry.Name()); err == nil && commands.Running(pid) {
+			continue
+		}
+		_ = os.RemoveAll(filepath.Join(parent, entry.Name()))
+	}
+}
+
+// RemoveStaleFiles deletes the output files left behind by processes that are
+// gone.
+func RemoveStaleFiles() {
+	removeStale(scrollbackDir)
+	removeStale(castsDir)
+}
+
+// RemoveFiles deletes the output files of this process.
+func RemoveFiles() {
+	for _, name := range []string{scrollbackDir, castsDir} {
+		_ = os.RemoveAll(filepath.Join(data.Path, name, strconv.Itoa(os.Getpid())))
+	}
+}
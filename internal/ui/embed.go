@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"github.com/creack/pty"
+	"github.com/tsukinoko-kun/ohmygosh/internal/term"
+	"github.com/tsukinoko-kun/ohmygosh/internal/ui/exit"
+)
+
+// embedSize returns the size of the pane of an embedded block. It fills the
+// viewport except for the header, the empty line below it and the margin.
+func (m *Model) embedSize() (cols, rows int) {
+	return max(1, m.Width-2), max(1, m.Viewport.Height-4)
+}
+
+// setEmbedded embeds the block while its program shows the alternate screen
+// and releases it once the program leaves it or finishes. An embedded block
+// is sized to its pane and gets the keyboard focus.
+func (m *Model) setEmbedded(block *CommandBlock, alt bool) {
+	if alt == block.Embedded {
+		return
+	}
+
+	block.Embedded = alt
+	if alt {
+		if m.FocusedBlock != nil {
+			m.FocusedBlock.Focused = false
+		}
+		m.FocusedBlock = block
+		block.Focused = true
+		m.Scrolling = false
+		m.Viewport.GotoBottom()
+	}
+	if block.PTY != nil {
+		exit.SetOwnSize(block.PTY, alt)
+	}
+	m.resizeBlock(block)
+}
+
+// resizeBlock sizes the screen and the PTY of the block to its pane if it is
+// embedded and to the terminal otherwise.
+func (m *Model) resizeBlock(block *CommandBlock) {
+	cols, rows := int(term.Cols), int(term.Rows)
+	if block.Embedded {
+		cols, rows = m.embedSize()
+	}
+	block.mu.Lock()
+	if block.Screen != nil {
+		block.Screen.Resize(cols, rows)
+	}
+	block.mu.Unlock()
+	if block.PTY != nil && block.IsRunning {
+		_ = pty.Setsize(block.PTY, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+		block.rec.resize(cols, rows)
+	}
+}
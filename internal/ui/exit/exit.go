@@ -3,6 +3,7 @@ package exit
 import (
 	"os"
 	"os/exec"
+	"sync"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/creack/pty"
@@ -12,11 +13,29 @@ var ExitCode int
 
 var P *tea.Program
 
-var size *pty.Winsize
+var (
+	size   *pty.Winsize
+	sizeMu sync.Mutex
+)
+
+// Size returns the size of the terminal or 0, 0 if it is unknown.
+func Size() (cols, rows int) {
+	sizeMu.Lock()
+	defer sizeMu.Unlock()
+	if size == nil {
+		return 0, 0
+	}
+	return int(size.Cols), int(size.Rows)
+}
 
 type Command struct {
 	Cmd *exec.Cmd
 	Pty *os.File
+	// OwnSize is set for commands that are sized by the UI instead of the
+	// terminal
+	OwnSize bool
+	// Resized is called after the PTY was resized to the terminal
+	Resized func(cols, rows int)
 }
 
 var TrackedCommands []*Command
@@ -28,21 +47,49 @@ func Exit(code int) {
 
 func TrackCommand(cmd *exec.Cmd, t *os.File) {
 	TrackedCommands = append(TrackedCommands, &Command{Cmd: cmd, Pty: t})
+	sizeMu.Lock()
+	defer sizeMu.Unlock()
 	if size != nil && t != nil {
 		_ = pty.Setsize(t, size)
 	}
 }
 
+// SetOwnSize sets whether the command on the PTY t keeps its own size when the
+// terminal is resized.
+func SetOwnSize(t *os.File, own bool) {
+	for _, cmd := range TrackedCommands {
+		if cmd.Pty == t {
+			cmd.OwnSize = own
+		}
+	}
+}
+
+// OnResize sets the function that is called when the PTY t is resized to the
+// terminal.
+func OnResize(t *os.File, fn func(cols, rows int)) {
+	for _, cmd := range TrackedCommands {
+		if cmd.Pty == t {
+			cmd.Resized = fn
+		}
+	}
+}
+
 func ClearTrackedCommands() {
 	TrackedCommands = nil
 }
 
-func InheritSize(size *pty.Winsize) error {
+func InheritSize(s *pty.Winsize) error {
+	sizeMu.Lock()
+	size = s
+	sizeMu.Unlock()
 	for _, cmd := range TrackedCommands {
-		if cmd.Pty != nil {
-			if err := pty.Setsize(cmd.Pty, size); err != nil {
+		if cmd.Pty != nil && !cmd.OwnSize {
+			if err := pty.Setsize(cmd.Pty, s); err != nil {
 				return err
 			}
+			if cmd.Resized != nil {
+				cmd.Resized(int(s.Cols), int(s.Rows))
+			}
 		}
 	}
 	return nil
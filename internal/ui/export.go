@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/export"
+)
+
+// exportBlock takes a snapshot of block for an export. The caller must hold
+// block.mu.
+func exportBlock(block *CommandBlock) (export.Block, error) {
+	output, err := block.fullOutput()
+	if err != nil {
+		return export.Block{}, err
+	}
+	b := export.Block{
+		Prompt:   block.Prompt,
+		Command:  block.Command,
+		Running:  block.IsRunning,
+		ExitCode: block.ExitCode,
+		Output:   output,
+	}
+	if !block.IsRunning {
+		b.Duration = block.EndTime.Sub(block.StartTime)
+	}
+	return b, nil
+}
+
+// exportBlocks renders the blocks with ids from first to last in format to w.
+// A first of 0 selects the last block.
+func (m *Model) exportBlocks(w io.Writer, format export.Format, first, last int) error {
+	var selected []*CommandBlock
+	for _, block := range m.Commands {
+		if first == 0 {
+			selected = []*CommandBlock{block}
+		} else if block.ID >= first && block.ID <= last {
+			selected = append(selected, block)
+		}
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("no blocks")
+	}
+
+	blocks := make([]export.Block, len(selected))
+	for i, block := range selected {
+		block.mu.Lock()
+		b, err := exportBlock(block)
+		block.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		blocks[i] = b
+	}
+	return export.Write(w, format, blocks)
+}
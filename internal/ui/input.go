@@ -0,0 +1,38 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsukinoko-kun/ohmygosh/internal/commands"
+	"github.com/tsukinoko-kun/ohmygosh/internal/term"
+	"github.com/tsukinoko-kun/ohmygosh/internal/ui/keyenc"
+)
+
+// sendKey writes the bytes a terminal would send for key to the PTY of the
+// block.
+// Blocks in the session shell have no controlling terminal, so the signals
+// the line discipline would generate for ctrl+c and ctrl+\ are sent directly.
+// ctrl+z suspends the block like alt+z, the kernel drops SIGTSTP for the
+// orphaned process groups of blocks.
+func (m *Model) sendKey(block *CommandBlock, key tea.KeyMsg) error {
+	if !key.Alt && term.Signals(block.PTY) {
+		switch key.Type {
+		case tea.KeyCtrlZ:
+			return m.suspend(block)
+		case tea.KeyCtrlC:
+			if block.Run != nil {
+				return block.Run.Signal(commands.Interrupt)
+			}
+		case tea.KeyCtrlBackslash:
+			if block.Run != nil {
+				return block.Run.Signal(commands.Quit)
+			}
+		}
+	}
+
+	b := keyenc.Encode(key, block.Keys)
+	if len(b) == 0 {
+		return nil
+	}
+	_, err := block.PTY.Write(b)
+	return err
+}
@@ -0,0 +1,232 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsukinoko-kun/ohmygosh/internal/builtins"
+	"github.com/tsukinoko-kun/ohmygosh/internal/commands"
+)
+
+// Jobs is the modal listing running and stopped blocks.
+type Jobs struct {
+	Cursor int
+	Active bool
+}
+
+// signal sends sig to all processes of the block.
+func (b *CommandBlock) signal(sig commands.Signal) error {
+	if b.Run != nil {
+		return b.Run.Signal(sig)
+	}
+	return commands.SignalCommand(b.Cmd, sig)
+}
+
+// runningBlocks returns the blocks that are running or stopped, oldest first.
+func (m *Model) runningBlocks() []*CommandBlock {
+	var running []*CommandBlock
+	for _, block := range m.Commands {
+		if block.IsRunning && !block.InDirectMode {
+			running = append(running, block)
+		}
+	}
+	return running
+}
+
+// jobs returns the running blocks as jobs for builtins.
+func (m *Model) jobs() []builtins.Job {
+	var jobs []builtins.Job
+	for _, block := range m.runningBlocks() {
+		jobs = append(jobs, builtins.Job{
+			ID:        block.ID,
+			Pid:       block.Pid(),
+			Command:   block.Command,
+			StartTime: block.StartTime,
+			Stopped:   block.Stopped,
+		})
+	}
+	return jobs
+}
+
+// job returns the running block with the given id.
+func (m *Model) job(id int) (*CommandBlock, error) {
+	for _, block := range m.Commands {
+		if block.ID != id {
+			continue
+		}
+		if !block.IsRunning || block.InDirectMode {
+			return nil, errors.New("job has terminated")
+		}
+		return block, nil
+	}
+	return nil, errors.New("no such job")
+}
+
+// suspend stops the block and returns keyboard focus to the prompt if the
+// block had it.
+func (m *Model) suspend(block *CommandBlock) error {
+	if block.Stopped {
+		return nil
+	}
+	if err := block.signal(commands.Suspend); err != nil {
+		return err
+	}
+	block.Stopped = true
+	if m.FocusedBlock == block {
+		m.unfocus()
+	}
+	return nil
+}
+
+// resume continues a stopped block.
+func (m *Model) resume(block *CommandBlock) error {
+	if !block.Stopped {
+		return nil
+	}
+	if err := block.signal(commands.Resume); err != nil {
+		return err
+	}
+	block.Stopped = false
+	return nil
+}
+
+// foreground resumes the block with the given id and gives it keyboard focus.
+func (m *Model) foreground(id int) error {
+	block, err := m.job(id)
+	if err != nil {
+		return err
+	}
+	if err := m.resume(block); err != nil {
+		return err
+	}
+	if m.FocusedBlock != nil {
+		m.FocusedBlock.Focused = false
+	}
+	m.FocusedBlock = block
+	block.Focused = true
+	return nil
+}
+
+// background resumes the block with the given id and keeps it running
+// without keyboard focus.
+func (m *Model) background(id int) error {
+	block, err := m.job(id)
+	if err != nil {
+		return err
+	}
+	if err := m.resume(block); err != nil {
+		return err
+	}
+	if m.FocusedBlock == block {
+		m.unfocus()
+	}
+	return nil
+}
+
+// unfocus returns keyboard focus to the prompt.
+func (m *Model) unfocus() {
+	if m.FocusedBlock != nil {
+		m.FocusedBlock.Focused = false
+		m.FocusedBlock = nil
+	}
+	m.Input.Focus()
+}
+
+// updateJobs handles key presses while the jobs modal is open.
+func (m Model) updateJobs(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	running := m.runningBlocks()
+	if m.Jobs.Cursor >= len(running) {
+		m.Jobs.Cursor = len(running) - 1
+	}
+	if m.Jobs.Cursor < 0 {
+		m.Jobs.Cursor = 0
+	}
+
+	var (
+		block *CommandBlock
+		err   error
+	)
+	if len(running) > 0 {
+		block = running[m.Jobs.Cursor]
+	}
+
+	switch msg.String() {
+	case "esc", "q", "alt+j":
+		m.Jobs.Active = false
+		return m, nil
+	case "up", "k":
+		if m.Jobs.Cursor > 0 {
+			m.Jobs.Cursor--
+		}
+		return m, nil
+	case "down", "j":
+		if m.Jobs.Cursor < len(running)-1 {
+			m.Jobs.Cursor++
+		}
+		return m, nil
+	case "enter", "f":
+		if block == nil {
+			return m, nil
+		}
+		if err = m.foreground(block.ID); err == nil {
+			m.Jobs.Active = false
+		}
+	case "b":
+		if block == nil {
+			return m, nil
+		}
+		err = m.background(block.ID)
+	case "z":
+		if block == nil {
+			return m, nil
+		}
+		err = m.suspend(block)
+	default:
+		return m, nil
+	}
+
+	if err != nil {
+		block.mu.Lock()
+		block.Output.WriteString(fmt.Sprintf("\n[Error: %v]\n", err))
+		block.mu.Unlock()
+	}
+	m.updateViewContent()
+	return m, nil
+}
+
+func (m Model) JobsView() string {
+	var renderedJobs []string
+	renderedJobs = append(renderedJobs, "(/, f/Enter foreground, b background, z suspend, Esc to close)")
+	running := m.runningBlocks()
+	if len(running) == 0 {
+		renderedJobs = append(renderedJobs, blurredStyle.Render("no jobs"))
+	}
+	for i, block := range running {
+		state := "Running"
+		if block.Stopped {
+			state = "Stopped"
+		}
+		runtime := time.Since(block.StartTime).Round(time.Second)
+		line := fmt.Sprintf("[%d] %-8s %7d  %8s  %s", block.ID, state, block.Pid(), runtime, block.Command)
+		if i == m.Jobs.Cursor {
+			renderedJobs = append(renderedJobs, focusedStyle.Render("> "+line))
+		} else {
+			renderedJobs = append(renderedJobs, blurredStyle.Render("  "+line))
+		}
+	}
+	modalContent := lipgloss.JoinVertical(lipgloss.Left,
+		renderedJobs...,
+	)
+	modalDialog := modalBoxStyle.Render(modalContent)
+	return lipgloss.Place(
+		m.Width,
+		m.Height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modalDialog,
+		lipgloss.WithWhitespaceForeground(dimmedColor),
+	)
+}
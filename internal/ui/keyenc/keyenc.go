@@ -0,0 +1,219 @@
+// Package keyenc translates key presses into the bytes an xterm compatible
+// terminal sends to the program running in it.
+package keyenc
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Mode holds the terminal modes a program can set that change what keys send.
+type Mode struct {
+	// AppCursor is DECCKM (CSI ? 1 h), cursor keys send SS3 instead of CSI.
+	AppCursor bool
+	// BracketedPaste is set by CSI ? 2004 h, pastes are wrapped in markers.
+	BracketedPaste bool
+}
+
+// Scan updates the modes from program output.
+// Sequences split across two calls are not recognized.
+func (m *Mode) Scan(output string) {
+	for {
+		i := strings.Index(output, "\x1b[?")
+		if i < 0 {
+			return
+		}
+		output = output[i+3:]
+
+		end := strings.IndexFunc(output, func(r rune) bool {
+			return (r < '0' || r > '9') && r != ';'
+		})
+		if end < 0 {
+			return
+		}
+		if output[end] != 'h' && output[end] != 'l' {
+			continue
+		}
+		set := output[end] == 'h'
+		for _, param := range strings.Split(output[:end], ";") {
+			switch param {
+			case "1":
+				m.AppCursor = set
+			case "2004":
+				m.BracketedPaste = set
+			}
+		}
+		output = output[end+1:]
+	}
+}
+
+// modifier parameters used by xterm for keys with modifiers
+const (
+	modShift     = 2
+	modAlt       = 3
+	modCtrl      = 5
+	modCtrlShift = 6
+)
+
+type cursorKey struct {
+	final byte
+	mod   int
+}
+
+// cursorKeys are encoded as CSI <final>, SS3 <final> in application mode or
+// CSI 1 ; <mod> <final> with modifiers.
+var cursorKeys = map[tea.KeyType]cursorKey{
+	tea.KeyUp:             {'A', 0},
+	tea.KeyDown:           {'B', 0},
+	tea.KeyRight:          {'C', 0},
+	tea.KeyLeft:           {'D', 0},
+	tea.KeyHome:           {'H', 0},
+	tea.KeyEnd:            {'F', 0},
+	tea.KeyShiftUp:        {'A', modShift},
+	tea.KeyShiftDown:      {'B', modShift},
+	tea.KeyShiftRight:     {'C', modShift},
+	tea.KeyShiftLeft:      {'D', modShift},
+	tea.KeyShiftHome:      {'H', modShift},
+	tea.KeyShiftEnd:       {'F', modShift},
+	tea.KeyCtrlUp:         {'A', modCtrl},
+	tea.KeyCtrlDown:       {'B', modCtrl},
+	tea.KeyCtrlRight:      {'C', modCtrl},
+	tea.KeyCtrlLeft:       {'D', modCtrl},
+	tea.KeyCtrlHome:       {'H', modCtrl},
+	tea.KeyCtrlEnd:        {'F', modCtrl},
+	tea.KeyCtrlShiftUp:    {'A', modCtrlShift},
+	tea.KeyCtrlShiftDown:  {'B', modCtrlShift},
+	tea.KeyCtrlShiftRight: {'C', modCtrlShift},
+	tea.KeyCtrlShiftLeft:  {'D', modCtrlShift},
+	tea.KeyCtrlShiftHome:  {'H', modCtrlShift},
+	tea.KeyCtrlShiftEnd:   {'F', modCtrlShift},
+}
+
+type tildeKey struct {
+	code int
+	mod  int
+}
+
+// tildeKeys are encoded as CSI <code> ~ or CSI <code> ; <mod> ~ with
+// modifiers.
+var tildeKeys = map[tea.KeyType]tildeKey{
+	tea.KeyInsert:     {2, 0},
+	tea.KeyDelete:     {3, 0},
+	tea.KeyPgUp:       {5, 0},
+	tea.KeyPgDown:     {6, 0},
+	tea.KeyCtrlPgUp:   {5, modCtrl},
+	tea.KeyCtrlPgDown: {6, modCtrl},
+	tea.KeyF5:         {15, 0},
+	tea.KeyF6:         {17, 0},
+	tea.KeyF7:         {18, 0},
+	tea.KeyF8:         {19, 0},
+	tea.KeyF9:         {20, 0},
+	tea.KeyF10:        {21, 0},
+	tea.KeyF11:        {23, 0},
+	tea.KeyF12:        {24, 0},
+	tea.KeyF13:        {25, 0},
+	tea.KeyF14:        {26, 0},
+	tea.KeyF15:        {28, 0},
+	tea.KeyF16:        {29, 0},
+	tea.KeyF17:        {31, 0},
+	tea.KeyF18:        {32, 0},
+	tea.KeyF19:        {33, 0},
+	tea.KeyF20:        {34, 0},
+}
+
+// ss3Keys are F1 to F4, encoded as SS3 <final> or CSI 1 ; <mod> <final>.
+var ss3Keys = map[tea.KeyType]byte{
+	tea.KeyF1: 'P',
+	tea.KeyF2: 'Q',
+	tea.KeyF3: 'R',
+	tea.KeyF4: 'S',
+}
+
+// Encode returns the bytes a terminal sends for key, or nil if the key has no
+// encoding.
+// Alt is sent as ESC prefix for characters and as modifier parameter for
+// special keys, like xterm does with metaSendsEscape.
+func Encode(key tea.KeyMsg, mode Mode) []byte {
+	if k, ok := cursorKeys[key.Type]; ok {
+		mod := withAlt(k.mod, key.Alt)
+		switch {
+		case mod != 0:
+			return []byte("\x1b[1;" + strconv.Itoa(mod) + string(k.final))
+		case mode.AppCursor:
+			return []byte{0x1b, 'O', k.final}
+		default:
+			return []byte{0x1b, '[', k.final}
+		}
+	}
+
+	if k, ok := tildeKeys[key.Type]; ok {
+		mod := withAlt(k.mod, key.Alt)
+		if mod != 0 {
+			return []byte("\x1b[" + strconv.Itoa(k.code) + ";" + strconv.Itoa(mod) + "~")
+		}
+		return []byte("\x1b[" + strconv.Itoa(k.code) + "~")
+	}
+
+	if final, ok := ss3Keys[key.Type]; ok {
+		if key.Alt {
+			return []byte("\x1b[1;" + strconv.Itoa(modAlt) + string(final))
+		}
+		return []byte{0x1b, 'O', final}
+	}
+
+	var b []byte
+	switch {
+	case key.Type == tea.KeyRunes:
+		b = make([]byte, 0, len(key.Runes)*utf8.UTFMax)
+		for _, r := range key.Runes {
+			b = utf8.AppendRune(b, r)
+		}
+	case key.Type == tea.KeySpace:
+		b = []byte{' '}
+	case key.Type == tea.KeyShiftTab:
+		return []byte("\x1b[Z")
+	case key.Type >= tea.KeyNull && key.Type <= tea.KeyCtrlUnderscore, key.Type == tea.KeyBackspace:
+		// control characters are sent as they are, backspace is DEL
+		b = []byte{byte(key.Type)}
+	default:
+		return nil
+	}
+
+	if key.Alt {
+		return append([]byte{0x1b}, b...)
+	}
+	return b
+}
+
+// withAlt adds the alt modifier to the xterm modifier parameter mod.
+func withAlt(mod int, alt bool) int {
+	if !alt {
+		return mod
+	}
+	if mod == 0 {
+		return modAlt
+	}
+	return mod + modAlt - 1
+}
+
+const (
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+)
+
+// Paste returns the bytes a terminal sends when text is pasted.
+// Line breaks are sent as carriage returns like typed enter keys. With
+// bracketed paste the text is wrapped in markers, an end marker inside the
+// text is removed so it can't end the paste early.
+func Paste(text string, mode Mode) []byte {
+	text = strings.ReplaceAll(text, "\r\n", "\r")
+	text = strings.ReplaceAll(text, "\n", "\r")
+	if !mode.BracketedPaste {
+		return []byte(text)
+	}
+	text = strings.ReplaceAll(text, pasteEnd, "")
+	return []byte(pasteStart + text + pasteEnd)
+}
@@ -0,0 +1,104 @@
+package keyenc_test
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsukinoko-kun/ohmygosh/internal/ui/keyenc"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      tea.KeyMsg
+		mode     keyenc.Mode
+		expected string
+	}{
+		{name: "runes", key: tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("aé")}, expected: "aé"},
+		{name: "alt rune", key: tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b"), Alt: true}, expected: "\x1bb"},
+		{name: "space", key: tea.KeyMsg{Type: tea.KeySpace}, expected: " "},
+		{name: "enter", key: tea.KeyMsg{Type: tea.KeyEnter}, expected: "\r"},
+		{name: "backspace", key: tea.KeyMsg{Type: tea.KeyBackspace}, expected: "\x7f"},
+		{name: "tab", key: tea.KeyMsg{Type: tea.KeyTab}, expected: "\t"},
+		{name: "shift tab", key: tea.KeyMsg{Type: tea.KeyShiftTab}, expected: "\x1b[Z"},
+		{name: "escape", key: tea.KeyMsg{Type: tea.KeyEsc}, expected: "\x1b"},
+		{name: "ctrl+c", key: tea.KeyMsg{Type: tea.KeyCtrlC}, expected: "\x03"},
+		{name: "ctrl+d", key: tea.KeyMsg{Type: tea.KeyCtrlD}, expected: "\x04"},
+		{name: "alt ctrl+a", key: tea.KeyMsg{Type: tea.KeyCtrlA, Alt: true}, expected: "\x1b\x01"},
+		{name: "up", key: tea.KeyMsg{Type: tea.KeyUp}, expected: "\x1b[A"},
+		{name: "up application mode", key: tea.KeyMsg{Type: tea.KeyUp}, mode: keyenc.Mode{AppCursor: true}, expected: "\x1bOA"},
+		{name: "home", key: tea.KeyMsg{Type: tea.KeyHome}, expected: "\x1b[H"},
+		{name: "end application mode", key: tea.KeyMsg{Type: tea.KeyEnd}, mode: keyenc.Mode{AppCursor: true}, expected: "\x1bOF"},
+		{name: "ctrl+left", key: tea.KeyMsg{Type: tea.KeyCtrlLeft}, expected: "\x1b[1;5D"},
+		{name: "alt+right", key: tea.KeyMsg{Type: tea.KeyRight, Alt: true}, expected: "\x1b[1;3C"},
+		{name: "alt ctrl+left", key: tea.KeyMsg{Type: tea.KeyCtrlLeft, Alt: true}, expected: "\x1b[1;7D"},
+		{name: "delete", key: tea.KeyMsg{Type: tea.KeyDelete}, expected: "\x1b[3~"},
+		{name: "ctrl+pgup", key: tea.KeyMsg{Type: tea.KeyCtrlPgUp}, expected: "\x1b[5;5~"},
+		{name: "f1", key: tea.KeyMsg{Type: tea.KeyF1}, expected: "\x1bOP"},
+		{name: "alt+f4", key: tea.KeyMsg{Type: tea.KeyF4, Alt: true}, expected: "\x1b[1;3S"},
+		{name: "f5", key: tea.KeyMsg{Type: tea.KeyF5}, expected: "\x1b[15~"},
+		{name: "f12", key: tea.KeyMsg{Type: tea.KeyF12}, expected: "\x1b[24~"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(keyenc.Encode(tt.key, tt.mode))
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestModeScan(t *testing.T) {
+	tests := []struct {
+		name           string
+		output         string
+		appCursor      bool
+		bracketedPaste bool
+	}{
+		{name: "no sequence", output: "hello", appCursor: false},
+		{name: "set", output: "\x1b[?1h\x1b=", appCursor: true},
+		{name: "set with other modes", output: "\x1b[?1049;1h", appCursor: true},
+		{name: "reset after set", output: "\x1b[?1h text \x1b[?1l", appCursor: false},
+		{name: "other mode", output: "\x1b[?12h", appCursor: false},
+		{name: "bracketed paste", output: "\x1b[?2004h", bracketedPaste: true},
+		{name: "bracketed paste and cursor", output: "\x1b[?1;2004h", appCursor: true, bracketedPaste: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mode keyenc.Mode
+			mode.Scan(tt.output)
+			if mode.AppCursor != tt.appCursor {
+				t.Errorf("Expected AppCursor %v, got %v", tt.appCursor, mode.AppCursor)
+			}
+			if mode.BracketedPaste != tt.bracketedPaste {
+				t.Errorf("Expected BracketedPaste %v, got %v", tt.bracketedPaste, mode.BracketedPaste)
+			}
+		})
+	}
+}
+
+func TestPaste(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		mode     keyenc.Mode
+		expected string
+	}{
+		{name: "plain", text: "echo hi", expected: "echo hi"},
+		{name: "line breaks", text: "a\nb\r\nc", expected: "a\rb\rc"},
+		{name: "bracketed", text: "a\nb", mode: keyenc.Mode{BracketedPaste: true}, expected: "\x1b[200~a\rb\x1b[201~"},
+		{name: "end marker in text", text: "a\x1b[201~rm -rf ~\n", mode: keyenc.Mode{BracketedPaste: true}, expected: "\x1b[200~arm -rf ~\r\x1b[201~"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(keyenc.Paste(tt.text, tt.mode))
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package ui
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+	"github.com/tsukinoko-kun/ohmygosh/internal/ui/ansicompiler"
+)
+
+// linkZone returns the zone id of a row of the output of a block.
+func linkZone(block *CommandBlock, row int) string {
+	return fmt.Sprintf("block_link_%d_%d", block.ID, row)
+}
+
+// outputLine returns a rendered row of the output of a block. Rows with links
+// are marked as zones, the link under the mouse is looked up by its column in
+// the row.
+func outputLine(block *CommandBlock, row int, line string) string {
+	if block.Screen != nil && block.Screen.HasLinks(row) {
+		return zone.Mark(linkZone(block, row), line)
+	}
+	return line
+}
+
+// linkAt returns the link under the mouse or an empty string.
+func (m *Model) linkAt(msg tea.MouseMsg) string {
+	for _, block := range m.Commands {
+		if block.Screen == nil {
+			continue
+		}
+		for row := 0; row < block.Screen.Rows(); row++ {
+			if !block.Screen.HasLinks(row) {
+				continue
+			}
+			// Only the start of the zone is used, the link sequences before
+			// the end confuse the width calculation of bubblezone.
+			z := zone.Get(linkZone(block, row))
+			if z.IsZero() || msg.Y != z.StartY || msg.X < z.StartX {
+				continue
+			}
+			return block.Screen.LinkAt(row, msg.X-z.StartX)
+		}
+	}
+	return ""
+}
+
+// openLink opens link with the configured opener. Output is untrusted, only
+// web and mail links are opened.
+func openLink(link string) tea.Cmd {
+	if !ansicompiler.SafeLink(link) {
+		return nil
+	}
+	return func() tea.Msg {
+		args := strings.Fields(config.Get.Ui.LinkOpener)
+		if len(args) == 0 {
+			return nil
+		}
+		_ = exec.Command(args[0], append(args[1:], link)...).Run()
+		return nil
+	}
+}
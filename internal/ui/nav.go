@@ -0,0 +1,208 @@
+package ui
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+	"github.com/tsukinoko-kun/ohmygosh/internal/neofetch"
+	textinput "github.com/tsukinoko-kun/ohmygosh/internal/ui/bubbles/vimtextinput"
+)
+
+// Nav is the block navigation mode, a cursor selects one of the blocks and
+// keys act on it.
+type Nav struct {
+	Selected *CommandBlock
+	Active   bool
+}
+
+// PagerFinishedMsg is sent when the pager showing the output of a block quits.
+type PagerFinishedMsg struct {
+	Err error
+	ID  int
+}
+
+// startNav enters the navigation mode with the last block selected.
+func (m *Model) startNav() {
+	if len(m.Commands) == 0 {
+		return
+	}
+	m.Nav.Active = true
+	m.selectBlock(m.Commands[len(m.Commands)-1])
+}
+
+// stopNav leaves the navigation mode.
+func (m *Model) stopNav() {
+	if m.Nav.Selected != nil {
+		m.Nav.Selected.Selected = false
+	}
+	m.Nav = Nav{}
+	m.updateViewContent()
+}
+
+// selectBlock moves the cursor to block and scrolls it into view.
+func (m *Model) selectBlock(block *CommandBlock) {
+	if m.Nav.Selected != nil {
+		m.Nav.Selected.Selected = false
+	}
+	m.Nav.Selected = block
+	block.Selected = true
+	m.Scrolling = true
+	m.updateViewContent()
+
+	index := m.blockIndex(block)
+	if neofetch.Print != "" {
+		// the banner is the first item
+		index++
+	}
+	m.Viewport.ShowItem(index)
+}
+
+// blockIndex returns the index of block in m.Commands or -1.
+func (m *Model) blockIndex(block *CommandBlock) int {
+	for i, b := range m.Commands {
+		if b == block {
+			return i
+		}
+	}
+	return -1
+}
+
+// updateNav handles key presses in the navigation mode.
+func (m Model) updateNav(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	block := m.Nav.Selected
+	index := m.blockIndex(block)
+	if index < 0 {
+		m.stopNav()
+		return m, nil
+	}
+
+	if action, ok := copyActionForKey(msg.String()); ok {
+		m.copyBlock(block, action)
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc", "q", "alt+b":
+		m.stopNav()
+	case "up", "k":
+		if index > 0 {
+			m.selectBlock(m.Commands[index-1])
+		}
+	case "down", "j":
+		if index < len(m.Commands)-1 {
+			m.selectBlock(m.Commands[index+1])
+		}
+	case "home", "g":
+		m.selectBlock(m.Commands[0])
+	case "end", "G":
+		m.selectBlock(m.Commands[len(m.Commands)-1])
+	case "y":
+		// the whole block, like alt+y
+		m.copyBlock(block, copyActions[0])
+	case "enter":
+		// Give keyboard focus to a running block
+		if block.IsRunning && !block.InDirectMode {
+			m.stopNav()
+			if err := m.foreground(block.ID); err != nil {
+				block.mu.Lock()
+				block.Output.WriteString(fmt.Sprintf("\n[Error: %v]\n", err))
+				block.mu.Unlock()
+			}
+			m.updateViewContent()
+		}
+	case "r":
+		m.stopNav()
+		m.Scrolling = false
+		return enterCommand(m, block.Command)
+	case "e":
+		m.stopNav()
+		m.editCommand(block.Command)
+	case "s":
+		m.stopNav()
+		m.editCommand(fmt.Sprintf("save -f output block-%d.txt %d", block.ID, block.ID))
+	case " ", "tab":
+		block.Collapsed = !block.Collapsed
+		m.selectBlock(block)
+	case "d", "delete":
+		cmd := m.deleteBlock(block)
+		if len(m.Commands) == 0 {
+			m.stopNav()
+		} else {
+			m.selectBlock(m.Commands[min(index, len(m.Commands)-1)])
+		}
+		return m, cmd
+	case "p":
+		return m, m.openPager(block)
+	}
+	return m, nil
+}
+
+// editCommand puts cmd in the prompt for editing and gives it keyboard focus.
+func (m *Model) editCommand(cmd string) {
+	m.unfocus()
+	m.Input.Reset()
+	m.Input.SetMode(textinput.ModeInsert)
+	m.Input.InsertText(cmd)
+	m.Scrolling = false
+	m.updateViewContent()
+}
+
+// deleteBlock removes block from the list, it is terminated if it still runs.
+func (m *Model) deleteBlock(block *CommandBlock) tea.Cmd {
+	var cmd tea.Cmd
+	if m.FocusedBlock == block {
+		m.unfocus()
+	}
+	block.mu.Lock()
+	if block.IsRunning {
+		cmd = terminateBlock(block)
+		if block.Cmd != nil && block.Cmd.Process != nil {
+			// CommandFinishedMsg doesn't find the block anymore, the process
+			// is waited for here so it doesn't stay a zombie
+			process := block.Cmd.Process
+			go func() { _, _ = process.Wait() }()
+		}
+	}
+	block.resetOutput()
+	_ = block.PTY.Close()
+	block.mu.Unlock()
+
+	i := m.blockIndex(block)
+	m.Commands = slices.Delete(m.Commands, i, i+1)
+	m.updateViewContent()
+	return cmd
+}
+
+// openPager shows the output of block in $PAGER, it gets the terminal until
+// it quits.
+func (m *Model) openPager(block *CommandBlock) tea.Cmd {
+	block.mu.Lock()
+	output, err := block.fullOutput()
+	block.mu.Unlock()
+	if err != nil {
+		return func() tea.Msg {
+			return PagerFinishedMsg{ID: block.ID, Err: err}
+		}
+	}
+
+	pager, _ := config.LookupEnviron("PAGER")
+	args := strings.Fields(pager)
+	if len(args) == 0 {
+		args = []string{"less", "-R"}
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = config.CopyEnviron()
+	cmd.Stdin = strings.NewReader(output)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return PagerFinishedMsg{ID: block.ID, Err: err}
+	})
+}
+
+// NavHelpView renders the keys of the navigation mode in place of the prompt.
+func (m Model) NavHelpView() string {
+	return blurredStyle.Render("(↑/↓ select, y copy, r re-run, e edit, space collapse, d delete, s save output, p pager, Enter focus, Esc to close)")
+}
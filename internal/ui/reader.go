@@ -0,0 +1,186 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+	"github.com/tsukinoko-kun/ohmygosh/internal/ui/exit"
+)
+
+// outputRingSize is the number of bytes a block buffers until the UI takes them.
+const outputRingSize = 1 << 20
+
+// OutputFrameMsg tells the UI that blocks have new output to draw.
+type OutputFrameMsg struct{}
+
+// outputRing is a fixed size buffer between the reader of the PTY of a block
+// and the UI. Writes block while it is full, so a command that writes faster
+// than it is drawn is slowed down instead of piling up output.
+type outputRing struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []byte
+	start  int
+	n      int
+	closed bool
+	// notify is called when new output was written
+	notify func()
+}
+
+func newOutputRing(size int, notify func()) *outputRing {
+	r := &outputRing{buf: make([]byte, size), notify: notify}
+	r.cond = sync.NewCond(&r.mu)
+	return r
+}
+
+func (r *outputRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	written := 0
+	for len(p) > 0 {
+		for r.n == len(r.buf) && !r.closed {
+			r.cond.Wait()
+		}
+		if r.closed {
+			return written, io.ErrClosedPipe
+		}
+		end := (r.start + r.n) % len(r.buf)
+		n := copy(r.buf[end:min(len(r.buf), end+len(r.buf)-r.n)], p)
+		r.n += n
+		p = p[n:]
+		written += n
+		r.cond.Broadcast()
+		r.notify()
+	}
+	return written, nil
+}
+
+// drain takes all buffered output.
+func (r *outputRing) drain() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var output string
+	if end := r.start + r.n; end <= len(r.buf) {
+		output = string(r.buf[r.start:end])
+	} else {
+		output = string(r.buf[r.start:]) + string(r.buf[:end-len(r.buf)])
+	}
+	r.start, r.n = 0, 0
+	r.cond.Broadcast()
+	return output
+}
+
+// wait blocks until there is output to drain. It returns false once the ring
+// is closed and empty.
+func (r *outputRing) wait() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for r.n == 0 && !r.closed {
+		r.cond.Wait()
+	}
+	return r.n > 0
+}
+
+// Close stops the ring, a blocked Write returns. Buffered output can still be
+// drained.
+func (r *outputRing) Close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	r.cond.Broadcast()
+}
+
+// frames limits how often the UI is told about new output. It is shared by all
+// blocks, so many busy commands don't draw more often than one.
+var frames frameLimiter
+
+type frameLimiter struct {
+	mu      sync.Mutex
+	pending bool
+	last    time.Time
+}
+
+// request sends an OutputFrameMsg once the current frame is over, requests
+// until then are merged into it.
+func (f *frameLimiter) request() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pending {
+		return
+	}
+	f.pending = true
+	interval := time.Second / time.Duration(max(config.Get.Ui.MaxFps, 1))
+	time.AfterFunc(time.Until(f.last.Add(interval)), f.send)
+}
+
+func (f *frameLimiter) send() {
+	f.mu.Lock()
+	f.pending = false
+	f.last = time.Now()
+	f.mu.Unlock()
+	exit.P.Send(OutputFrameMsg{})
+}
+
+// drain moves the output the reader buffered to Output and returns it. The
+// caller must hold b.mu.
+func (b *CommandBlock) drain() string {
+	output := b.ring.drain()
+	b.Output.WriteString(output)
+	b.Keys.Scan(output)
+	return output
+}
+
+// readOutput reads the PTY of the block until it is closed. The output is
+// recorded and buffered in the ring of the block to be drawn with the next
+// frame.
+func readOutput(block *CommandBlock) tea.Cmd {
+	ring := newOutputRing(outputRingSize, frames.request)
+	block.ring = ring
+	rec := newRecording(block)
+	block.rec = rec
+	if rec != nil {
+		exit.OnResize(block.PTY, rec.resize)
+	}
+	return func() tea.Msg {
+		defer ring.Close()
+		defer rec.close()
+		buf := make([]byte, 4096)
+		for {
+			n, err := block.PTY.Read(buf)
+			if n > 0 {
+				rec.output(buf[:n])
+				if _, err := ring.Write(buf[:n]); err != nil {
+					break
+				}
+
+				// Check for alt screen sequences, the UI embeds the block once
+				// they are drawn. The UI doesn't take messages while a program
+				// has the terminal, the reader must not wait for it.
+				if detectAltScreen(string(buf[:n])) {
+					go exit.P.Send(AltScreenDetectedMsg{ID: block.ID})
+				}
+			}
+			if err != nil {
+				// Linux reports EIO once every process closed its end of the tty
+				if err != io.EOF && !errors.Is(err, syscall.EIO) && !errors.Is(err, os.ErrClosed) {
+					_, _ = fmt.Fprintf(ring, "Error reading: %v\n", err)
+				}
+				break
+			}
+		}
+		return CommandFinishedMsg{ID: block.ID}
+	}
+}
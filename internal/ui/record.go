@@ -0,0 +1,200 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/tsukinoko-kun/ohmygosh/internal/cast"
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+	"github.com/tsukinoko-kun/ohmygosh/internal/term"
+	"github.com/tsukinoko-kun/ohmygosh/internal/ui/exit"
+)
+
+// recording is the raw output of the PTY of a block as an asciicast, it is
+// written to a file in the data directory while the block runs. Blocks are
+// only recorded if ui.record is set. Recording fails silently, the block runs
+// the same without it.
+type recording struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *cast.Writer
+	closed bool
+}
+
+// newRecording starts the recording of block with the size of the terminal.
+// It returns nil if recording is disabled.
+func newRecording(block *CommandBlock) *recording {
+	if !config.Get.Ui.Record {
+		return nil
+	}
+	r := &recording{}
+	dir, err := processDir(castsDir)
+	if err != nil {
+		return r
+	}
+	file, err := os.CreateTemp(dir, fmt.Sprintf("block-%d-*.cast", block.ID))
+	if err != nil {
+		return r
+	}
+
+	cols, rows := exit.Size()
+	if cols == 0 || rows == 0 {
+		cols, rows = int(term.Cols), int(term.Rows)
+	}
+	writer, err := cast.NewWriter(file, cast.Header{
+		Width:     cols,
+		Height:    rows,
+		Timestamp: block.StartTime.Unix(),
+		Command:   block.Command,
+	})
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return r
+	}
+	r.file = file
+	r.writer = writer
+	return r
+}
+
+// output records output of the PTY.
+func (r *recording) output(p []byte) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writer != nil && !r.closed {
+		_ = r.writer.Output(p)
+	}
+}
+
+// resize records a new size of the PTY.
+func (r *recording) resize(cols, rows int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writer != nil && !r.closed {
+		_ = r.writer.Resize(cols, rows)
+	}
+}
+
+// close ends the recording, the file is kept until remove.
+func (r *recording) close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file != nil && !r.closed {
+		_ = r.file.Close()
+	}
+	r.closed = true
+}
+
+// path returns the path of the file or an empty string if there is none.
+func (r *recording) path() string {
+	if r == nil || r.file == nil {
+		return ""
+	}
+	return r.file.Name()
+}
+
+// read calls fn with the recorded file, nothing is recorded meanwhile.
+func (r *recording) read(fn func(io.Reader) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, err := os.Open(r.file.Name())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return fn(f)
+}
+
+// remove ends the recording and deletes the file.
+func (r *recording) remove() {
+	if r == nil {
+		return
+	}
+	r.close()
+	if r.file != nil {
+		_ = os.Remove(r.file.Name())
+	}
+}
+
+// castBlocks writes the recordings of the blocks with ids from first to last
+// as one asciicast to w. The blocks keep their distance in time, each starts
+// with its command line. A first of 0 selects the last recorded block.
+func (m *Model) castBlocks(w io.Writer, first, last int) error {
+	var blocks []*CommandBlock
+	for _, block := range m.Commands {
+		if block.rec.path() == "" {
+			continue
+		}
+		if first == 0 {
+			blocks = []*CommandBlock{block}
+		} else if block.ID >= first && block.ID <= last {
+			blocks = append(blocks, block)
+		}
+	}
+	if len(blocks) == 0 {
+		if !config.Get.Ui.Record {
+			return fmt.Errorf("no recorded blocks, set ui.record in the config to record them")
+		}
+		return fmt.Errorf("no recorded blocks")
+	}
+
+	var writer *cast.Writer
+	for _, block := range blocks {
+		err := block.rec.read(func(f io.Reader) error {
+			reader, err := cast.NewReader(f)
+			if err != nil {
+				return err
+			}
+			if writer == nil {
+				header := reader.Header
+				if len(blocks) > 1 {
+					header.Command = ""
+				}
+				if writer, err = cast.NewWriter(w, header); err != nil {
+					return err
+				}
+			}
+
+			offset := block.StartTime.Sub(blocks[0].StartTime).Seconds()
+			commandLine := "$ " + strings.ReplaceAll(block.Command, "\n", "\r\n") + "\r\n"
+			if err := writer.WriteEvent(cast.Event{Time: offset, Type: cast.Output, Data: commandLine}); err != nil {
+				return err
+			}
+			if len(blocks) > 1 {
+				size := cast.ResizeData(reader.Header.Width, reader.Header.Height)
+				if err := writer.WriteEvent(cast.Event{Time: offset, Type: cast.Resize, Data: size}); err != nil {
+					return err
+				}
+			}
+			for {
+				e, err := reader.Next()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				e.Time += offset
+				if err := writer.WriteEvent(e); err != nil {
+					return err
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+)
+
+// spill is the file that keeps the lines a block dropped from its scrollback.
+// It is created when the first line is written.
+type spill struct {
+	id   int
+	file *os.File
+	err  error
+}
+
+func (s *spill) Write(p []byte) (int, error) {
+	if s.file == nil && s.err == nil {
+		var dir string
+		if dir, s.err = processDir(scrollbackDir); s.err == nil {
+			s.file, s.err = os.CreateTemp(dir, fmt.Sprintf("block-%d-*.log", s.id))
+		}
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.file.Write(p)
+}
+
+// path returns the path of the file or an empty string if there is none.
+func (s *spill) path() string {
+	if s == nil || s.file == nil {
+		return ""
+	}
+	return s.file.Name()
+}
+
+// remove deletes the file.
+func (s *spill) remove() {
+	if s == nil || s.file == nil {
+		return
+	}
+	_ = s.file.Close()
+	_ = os.Remove(s.file.Name())
+	s.file = nil
+}
+
+// truncatedMarker returns the line shown above the output of a block that
+// dropped lines from its scrollback or an empty string.
+func truncatedMarker(block *CommandBlock) string {
+	n := block.Screen.Truncated()
+	if n == 0 {
+		return ""
+	}
+	if path := block.spill.path(); path != "" {
+		return fmt.Sprintf("[%d lines truncated, see %s]", n, path)
+	}
+	return fmt.Sprintf("[%d lines truncated]", n)
+}
+
+// fullOutput returns the rendered output of a block including the lines that
+// were dropped from its scrollback. The caller must hold block.mu.
+func (b *CommandBlock) fullOutput() (string, error) {
+	output := b.compile().Render()
+	path := b.spill.path()
+	if path == "" {
+		return output, nil
+	}
+	spilled, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(spilled) + output, nil
+}
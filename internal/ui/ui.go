@@ -2,20 +2,18 @@ package ui
 
 import (
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
-	"unicode/utf8"
 
-	"github.com/atotto/clipboard"
-	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/creack/pty"
 	zone "github.com/lrstanley/bubblezone"
+	"github.com/tsukinoko-kun/ohmygosh/internal/builtins"
 	"github.com/tsukinoko-kun/ohmygosh/internal/commands"
 	"github.com/tsukinoko-kun/ohmygosh/internal/config"
 	"github.com/tsukinoko-kun/ohmygosh/internal/history"
@@ -25,7 +23,9 @@ import (
 	"github.com/tsukinoko-kun/ohmygosh/internal/term"
 	"github.com/tsukinoko-kun/ohmygosh/internal/ui/ansicompiler"
 	textinput "github.com/tsukinoko-kun/ohmygosh/internal/ui/bubbles/vimtextinput"
+	"github.com/tsukinoko-kun/ohmygosh/internal/ui/bubbles/vlist"
 	"github.com/tsukinoko-kun/ohmygosh/internal/ui/exit"
+	"github.com/tsukinoko-kun/ohmygosh/internal/ui/keyenc"
 )
 
 type CopyStatus uint8
@@ -48,20 +48,35 @@ type CommandBlock struct {
 	ExitCode      int
 	PTY           *os.File
 	Cmd           *exec.Cmd
-	OutputChan    chan string
+	Run           *shell.Run
+	Keys          keyenc.Mode
+	Screen        *ansicompiler.Buffer
+	ring          *outputRing
+	rec           *recording
 	mu            sync.Mutex
+	spill         *spill
+	header        *blockHeader
 	CopyStatus    CopyStatus
 	IsRunning     bool
+	Stopped       bool
 	Focused       bool
+	Selected      bool
+	Collapsed     bool
 	UsesAltScreen bool
 	InDirectMode  bool
+	// Embedded is set while the block shows the alternate screen of its
+	// program in a pane, Passthrough while its program has the terminal
+	Embedded    bool
+	Passthrough bool
 }
 
 // Model represents the application state
 type Model struct {
 	Input        textinput.Model
-	Viewport     viewport.Model
+	Viewport     vlist.Model
 	Cmp          Cmp
+	Jobs         Jobs
+	Nav          Nav
 	Commands     []*CommandBlock
 	FocusedBlock *CommandBlock
 	NextID       int
@@ -78,11 +93,6 @@ type Cmp struct {
 }
 
 // Message types
-type CommandOutputMsg struct {
-	Output string
-	ID     int
-}
-
 type CommandFinishedMsg struct {
 	ID int
 }
@@ -91,27 +101,88 @@ type AltScreenDetectedMsg struct {
 	ID int
 }
 
+type PassthroughFinishedMsg struct {
+	Err error
+	ID  int
+}
+
 type DirectModeFinishedMsg struct {
 	ID       int
 	ExitCode int
 }
 
+type BlockTerminatedMsg struct {
+	Err       error
+	Survivors []int
+	ID        int
+}
+
+// Pid returns the process id of the process running the block or 0.
+func (b *CommandBlock) Pid() int {
+	if b.Run != nil {
+		return b.Run.Pid
+	}
+	if b.Cmd != nil && b.Cmd.Process != nil {
+		return b.Cmd.Process.Pid
+	}
+	return 0
+}
+
+// terminate stops all processes of the block and returns the ones that had to
+// be killed.
+func (b *CommandBlock) terminate() ([]int, error) {
+	if b.Run != nil {
+		return b.Run.Terminate()
+	}
+	return commands.TerminateCommand(b.Cmd)
+}
+
+// terminateBlock terminates the block in the background, this can take up to
+// two seconds.
+func terminateBlock(block *CommandBlock) tea.Cmd {
+	return func() tea.Msg {
+		survivors, err := block.terminate()
+		return BlockTerminatedMsg{ID: block.ID, Survivors: survivors, Err: err}
+	}
+}
+
+// compile moves the output that arrived since the last call to the screen of
+// the block and returns the screen, Output only holds output that wasn't
+// compiled yet. The caller must hold b.mu.
+func (b *CommandBlock) compile() *ansicompiler.Buffer {
+	if b.Screen == nil {
+		b.spill = &spill{id: b.ID}
+		b.Screen = ansicompiler.NewBuffer()
+		b.Screen.SetScrollback(int(config.Get.Ui.ScrollbackLines), b.spill)
+	}
+	b.drain()
+	if b.Output.Len() > 0 {
+		_, _ = b.Screen.WriteString(b.Output.String())
+		b.Output.Reset()
+	}
+	return b.Screen
+}
+
+// resetOutput clears the output and the screen of the block. The caller must
+// hold b.mu.
+func (b *CommandBlock) resetOutput() {
+	b.ring.Close()
+	b.ring = nil
+	b.Output.Reset()
+	b.Screen = nil
+	b.spill.remove()
+	b.spill = nil
+	b.rec.remove()
+	b.rec = nil
+}
+
 func InitialModel() Model {
 	input := textinput.New()
 	input.SetMode(textinput.ModeInsert)
 	input.Focus()
 	input.SetWidth(80)
 
-	viewport := viewport.New(80, 20)
-	viewport.KeyMap.PageDown.Unbind()
-	viewport.KeyMap.PageUp.Unbind()
-	viewport.KeyMap.HalfPageUp.Unbind()
-	viewport.KeyMap.HalfPageDown.Unbind()
-	viewport.KeyMap.Down.Unbind()
-	viewport.KeyMap.Up.Unbind()
-	viewport.KeyMap.Left.Unbind()
-	viewport.KeyMap.Right.Unbind()
-	viewport.SetContent("")
+	viewport := vlist.New(80, 20)
 
 	return Model{
 		Commands: []*CommandBlock{},
@@ -123,21 +194,28 @@ func InitialModel() Model {
 
 func ExecuteCommand(cmd string, id int) (*CommandBlock, tea.Cmd) {
 	block := &CommandBlock{
-		ID:         id,
-		Command:    cmd,
-		Prompt:     prompt.Get(),
-		IsRunning:  true,
-		ExitCode:   -1,
-		StartTime:  time.Now(),
-		OutputChan: make(chan string),
+		ID:        id,
+		Command:   cmd,
+		Prompt:    prompt.Get(),
+		IsRunning: true,
+		ExitCode:  -1,
+		StartTime: time.Now(),
 	}
 
-	sh, shellArgs := shell.GetShellArgv()
-	fullCmd := exec.Command(sh, append(shellArgs, shell.Escape(shell.Wrap(shell.Aliases()+cmd)))...)
-	fullCmd.Env = config.Environ
+	// Prefer the session shell so state carries over between blocks.
+	// If it is busy with another block, run this one in its own process.
+	if run, err := shell.RunInSession(id, cmd); err == nil {
+		exit.TrackCommand(nil, run.PTY)
+		block.PTY = run.PTY
+		block.Run = run
+		return block, readOutput(block)
+	}
+
+	fullCmd := shell.Command(id, cmd)
 
 	ptmx, err := pty.Start(fullCmd)
 	if err != nil {
+		shell.ForgetCommand(id)
 		block.Output.WriteString(fmt.Sprintf("Error: %v\n", err))
 		block.IsRunning = false
 		block.EndTime = time.Now()
@@ -148,37 +226,7 @@ func ExecuteCommand(cmd string, id int) (*CommandBlock, tea.Cmd) {
 	block.PTY = ptmx
 	block.Cmd = fullCmd
 
-	readOutput := func() tea.Msg {
-		buf := make([]byte, 4096)
-		for {
-			n, err := ptmx.Read(buf)
-			if n > 0 {
-				output := string(buf[:n])
-
-				// Check for alt screen sequences
-				if detectAltScreen(output) {
-					return AltScreenDetectedMsg{ID: block.ID}
-				}
-
-				return CommandOutputMsg{
-					ID:     block.ID,
-					Output: output,
-				}
-			}
-			if err != nil {
-				if err != io.EOF {
-					return CommandOutputMsg{
-						ID:     block.ID,
-						Output: fmt.Sprintf("Error reading: %v\n", err),
-					}
-				}
-				break
-			}
-		}
-		return CommandFinishedMsg{ID: block.ID}
-	}
-
-	return block, readOutput
+	return block, readOutput(block)
 }
 
 func ExecuteCommandFullScreen(cmd string, id int) (*CommandBlock, tea.Cmd) {
@@ -189,39 +237,22 @@ func ExecuteCommandFullScreen(cmd string, id int) (*CommandBlock, tea.Cmd) {
 		IsRunning:     true,
 		ExitCode:      -1,
 		StartTime:     time.Now(),
-		OutputChan:    nil,
 		UsesAltScreen: true,
 		InDirectMode:  true,
 	}
 
-	sh, shellArgs := shell.GetShellArgv()
-	fullCmd := exec.Command(sh, append(shellArgs, shell.Escape(shell.Wrap(shell.Aliases()+cmd)))...)
-	exit.TrackCommand(fullCmd, nil)
-	fullCmd.Env = config.Environ
 	block.Output.WriteString("[Running in full-screen mode...]\n")
 
-	return block, tea.ExecProcess(fullCmd, func(err error) tea.Msg {
-		exitCode := 0
-		if err != nil {
-			if exitError, ok := err.(*exec.ExitError); ok {
-				exitCode = exitError.ExitCode()
-			} else {
-				exitCode = 1
-			}
-		}
-		return DirectModeFinishedMsg{ID: id, ExitCode: exitCode}
-	})
+	return block, executeInDirectMode(id, cmd)
 }
 
 // Common alt screen sequences:
 // \x1b[?1049h - Enter alt screen (used by modern terminals)
 // \x1b[?47h   - Enter alt screen (older)
-// \x1b[2J     - Clear screen (often used by TUI apps)
-// \x1b[H      - Home cursor (often combined with clear)
+// Clearing the screen is no sign of a TUI, `clear && make` does it too.
 var altScreenSequences = []string{
-	"\x1b[?1049h",   // Modern alt screen
-	"\x1b[?47h",     // Older alt screen
-	"\x1b[2J\x1b[H", // Clear screen + home (common TUI pattern)
+	"\x1b[?1049h", // Modern alt screen
+	"\x1b[?47h",   // Older alt screen
 }
 
 // detectAltScreen checks if the output contains alt screen escape sequences
@@ -234,11 +265,11 @@ func detectAltScreen(output string) bool {
 	return false
 }
 
+// executeInDirectMode runs cmd with the terminal of ohmygosh, the UI is
+// suspended until it exits.
 func executeInDirectMode(id int, cmd string) tea.Cmd {
-	sh, shellArgs := shell.GetShellArgv()
-	fullCmd := exec.Command(sh, append(shellArgs, shell.Escape(shell.Wrap(shell.Aliases()+cmd)))...)
+	fullCmd := shell.Command(id, cmd)
 	exit.TrackCommand(fullCmd, nil)
-	fullCmd.Env = config.Environ
 
 	return tea.ExecProcess(fullCmd, func(err error) tea.Msg {
 		exitCode := 0
@@ -284,6 +315,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
+	if msg, ok := msg.(tea.KeyMsg); ok && m.Jobs.Active {
+		return m.updateJobs(msg)
+	}
+
+	if msg, ok := msg.(tea.KeyMsg); ok && m.Nav.Active {
+		return m.updateNav(msg)
+	}
+
 	var cmds []tea.Cmd
 
 	switch msg := msg.(type) {
@@ -307,13 +346,43 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Global keybindings
 		switch msg.String() {
 		case "ctrl+c":
+			// Interrupt the focused command, it is sent to the PTY below
+			if m.FocusedBlock != nil && m.FocusedBlock.IsRunning {
+				break
+			}
 			// Cancel all running commands
 			for _, block := range m.Commands {
 				if block.IsRunning {
-					_ = commands.TerminateCommand(block.Cmd)
+					cmds = append(cmds, terminateBlock(block))
+				}
+			}
+		case "alt+z":
+			// Suspend the focused block and return to the prompt
+			if m.FocusedBlock != nil && m.FocusedBlock.IsRunning {
+				block := m.FocusedBlock
+				if err := m.suspend(block); err != nil {
+					block.mu.Lock()
+					block.Output.WriteString(fmt.Sprintf("\n[Error suspending: %v]\n", err))
+					block.mu.Unlock()
 				}
+				m.updateViewContent()
+				return m, nil
 			}
+		case "alt+j":
+			m.Jobs.Active = true
+			m.Jobs.Cursor = len(m.runningBlocks()) - 1
+			return m, nil
+		case "alt+b":
+			// readline moves a word back on alt+b
+			if m.FocusedBlock != nil && m.FocusedBlock.IsRunning {
+				break
+			}
+			m.startNav()
+			return m, nil
 		case "enter":
+			if m.FocusedBlock != nil {
+				break
+			}
 			if cmd := strings.TrimSpace(m.Input.Value()); cmd != "" {
 				return enterCommand(m, cmd)
 			}
@@ -335,6 +404,18 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, nil
 
+		case "alt+esc":
+			// esc returns to the prompt, pressing it twice sends it to the command
+			if m.FocusedBlock != nil && m.FocusedBlock.IsRunning {
+				block := m.FocusedBlock
+				if err := m.sendKey(block, tea.KeyMsg{Type: tea.KeyEsc}); err != nil {
+					block.mu.Lock()
+					block.Output.WriteString(fmt.Sprintf("Error sending input: %v\n", err))
+					block.mu.Unlock()
+				}
+				return m, nil
+			}
+
 		case "tab":
 			if m.FocusedBlock == nil && !m.Cmp.Active && m.Input.Focused() {
 				cmp, err := shell.GetCompletions(m.Input.Value(), m.Input.Cursor())
@@ -431,35 +512,37 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.updateViewContent()
 				return m, nil
 			}
+
+		default:
+			// A running block with the keyboard gets the keys, programs
+			// use them too, readline capitalizes a word on alt+c.
+			if m.FocusedBlock != nil && m.FocusedBlock.IsRunning {
+				break
+			}
+			if action, ok := copyActionForKey(msg.String()); ok {
+				if block := m.copyTarget(); block != nil {
+					m.copyBlock(block, action)
+					return m, nil
+				}
+			}
 		}
 
 		// If a block is focused, send input to its PTY
 		if m.FocusedBlock != nil && m.FocusedBlock.IsRunning {
+			block := m.FocusedBlock
 			if msg.Paste {
-				cb, _ := clipboard.ReadAll()
-				if _, err := m.FocusedBlock.PTY.WriteString(cb); err != nil {
-					m.FocusedBlock.Output.WriteString(fmt.Sprintf("Error sending input: %v\n", err))
+				if _, err := block.PTY.Write(keyenc.Paste(string(msg.Runes), block.Keys)); err != nil {
+					block.mu.Lock()
+					block.Output.WriteString(fmt.Sprintf("Error sending input: %v\n", err))
+					block.mu.Unlock()
 					m.updateViewContent()
 				}
 				return m, nil
 			}
-			switch msg.String() {
-			case "enter":
-				if _, err := m.FocusedBlock.PTY.WriteString("\n"); err != nil {
-					m.FocusedBlock.Output.WriteString(fmt.Sprintf("Error sending input: %v\n", err))
-				}
-			case "backspace":
-				if _, err := m.FocusedBlock.PTY.WriteString("\b \b"); err != nil {
-					m.FocusedBlock.Output.WriteString(fmt.Sprintf("Error sending input: %v\n", err))
-				}
-			default:
-				for _, r := range msg.Runes {
-					b := make([]byte, utf8.RuneLen(r))
-					utf8.EncodeRune(b, r)
-					if _, err := m.FocusedBlock.PTY.Write(b); err != nil {
-						m.FocusedBlock.Output.WriteString(fmt.Sprintf("Error sending input: %v\n", err))
-					}
-				}
+			if err := m.sendKey(block, msg); err != nil {
+				block.mu.Lock()
+				block.Output.WriteString(fmt.Sprintf("Error sending input: %v\n", err))
+				block.mu.Unlock()
 			}
 			m.updateViewContent()
 			return m, nil
@@ -481,6 +564,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Update input width
 		m.Input.SetWidth(msg.Width)
 
+		// Embedded blocks follow the size of the viewport, the others the
+		// size of the terminal
+		for _, block := range m.Commands {
+			if block.Embedded {
+				m.resizeBlock(block)
+			}
+		}
+
 		m.updateViewContent()
 
 	case tea.MouseMsg:
@@ -499,23 +590,21 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case tea.MouseButtonLeft:
 			for _, block := range m.Commands {
-				if zone.Get(fmt.Sprintf("block_copy_%d", block.ID)).InBounds(msg) {
-					err := clipboard.WriteAll(fmt.Sprintf("$ %s\n%s", block.Command, ansicompiler.CompileAnsi(block.Output.String())))
-					if err != nil {
-						block.CopyStatus = CopyStatusFailure
-						block.CopyError = err.Error()
-					} else {
-						block.CopyStatus = CopyStatusSuccess
-					}
-					m.updateViewContent()
-					return m, nil
-				} else if zone.Get(fmt.Sprintf("block_cancel_%d", block.ID)).InBounds(msg) {
+				if zone.Get(fmt.Sprintf("block_cancel_%d", block.ID)).InBounds(msg) {
 					block.IsRunning = false
 					block.ExitCode = 130
 					block.CopyStatus = CopyStatusNone
-					_ = commands.TerminateCommand(block.Cmd)
 					m.updateViewContent()
-					return m, nil
+					return m, terminateBlock(block)
+				}
+			}
+			if block, action, ok := m.copyActionAt(msg); ok {
+				m.copyBlock(block, action)
+				return m, nil
+			}
+			if msg.Action == tea.MouseActionPress {
+				if link := m.linkAt(msg); link != "" {
+					return m, openLink(link)
 				}
 			}
 
@@ -526,23 +615,37 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case AltScreenDetectedMsg:
 		for _, block := range m.Commands {
 			if block.ID == msg.ID {
-				// Clean up PTY
-				if block.PTY != nil {
-					_ = block.PTY.Close()
-				}
-				if block.Cmd != nil && block.Cmd.Process != nil {
-					_ = commands.TerminateCommand(block.Cmd)
-				}
-
-				block.UsesAltScreen = true
-				block.InDirectMode = true
-				block.Output.Reset()
-				block.Output.WriteString("[Restarting in full-screen mode...]\n")
+				cmds = append(cmds, m.syncAltScreen(block))
+				m.updateViewContent()
+				break
+			}
+		}
 
+	case PassthroughFinishedMsg:
+		for _, block := range m.Commands {
+			if block.ID == msg.ID {
+				block.Passthrough = false
+				if msg.Err != nil {
+					block.mu.Lock()
+					block.Output.WriteString(fmt.Sprintf("\n[Error passing the terminal through: %v]\n", msg.Err))
+					block.mu.Unlock()
+				}
+				m.resizeBlock(block)
 				m.updateViewContent()
+				break
+			}
+		}
 
-				// Execute in direct mode
-				return m, executeInDirectMode(block.ID, block.Command)
+	case PagerFinishedMsg:
+		if msg.Err != nil {
+			for _, block := range m.Commands {
+				if block.ID == msg.ID {
+					block.mu.Lock()
+					block.Output.WriteString(fmt.Sprintf("\n[Error opening the pager: %v]\n", msg.Err))
+					block.mu.Unlock()
+					m.updateViewContent()
+					break
+				}
 			}
 		}
 
@@ -550,6 +653,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Find the block that was in direct mode and mark it as finished
 		for _, block := range m.Commands {
 			if block.InDirectMode {
+				shell.ForgetCommand(block.ID)
 				block.InDirectMode = false
 				block.IsRunning = false
 				block.ExitCode = msg.ExitCode
@@ -560,36 +664,30 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
-	case CommandOutputMsg:
-		// Find the command block and update its output
+	case OutputFrameMsg:
+		for _, block := range m.Commands {
+			cmds = append(cmds, m.syncAltScreen(block))
+		}
+		m.updateViewContent()
+
+	case BlockTerminatedMsg:
+		// Report processes that didn't stop gracefully
 		for _, block := range m.Commands {
 			if block.ID == msg.ID {
 				block.mu.Lock()
-				block.Output.WriteString(msg.Output)
+				block.drain()
+				if msg.Err != nil {
+					block.Output.WriteString(fmt.Sprintf("\n[Error terminating: %v]\n", msg.Err))
+				}
+				if len(msg.Survivors) > 0 {
+					pids := make([]string, len(msg.Survivors))
+					for i, pid := range msg.Survivors {
+						pids[i] = strconv.Itoa(pid)
+					}
+					block.Output.WriteString(fmt.Sprintf("\n[Killed, still alive after SIGTERM: %s]\n", strings.Join(pids, ", ")))
+				}
 				block.mu.Unlock()
 				m.updateViewContent()
-
-				// Continue reading from the PTY
-				cmds = append(cmds, func() tea.Msg {
-					buf := make([]byte, 4096)
-					n, err := block.PTY.Read(buf)
-					if n > 0 {
-						return CommandOutputMsg{
-							ID:     block.ID,
-							Output: string(buf[:n]),
-						}
-					}
-					if err != nil {
-						if err != io.EOF {
-							return CommandOutputMsg{
-								ID:     block.ID,
-								Output: fmt.Sprintf("Error reading: %v\n", err),
-							}
-						}
-						return CommandFinishedMsg{ID: block.ID}
-					}
-					return nil
-				})
 				break
 			}
 		}
@@ -599,9 +697,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		for _, block := range m.Commands {
 			if block.ID == msg.ID {
 				block.mu.Lock()
+				block.drain()
+				block.ring = nil
 				block.IsRunning = false
+				block.Stopped = false
 				block.EndTime = time.Now()
-				if ps, err := block.Cmd.Process.Wait(); err == nil {
+				// a command that was killed didn't report its environment
+				shell.ForgetCommand(block.ID)
+				if block.Run != nil {
+					block.ExitCode = block.Run.Wait()
+				} else if ps, err := block.Cmd.Process.Wait(); err == nil {
 					block.ExitCode = ps.ExitCode()
 				} else if exitError, ok := err.(*exec.ExitError); ok {
 					block.ExitCode = exitError.ExitCode()
@@ -609,6 +714,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					block.ExitCode = -1
 				}
 				block.mu.Unlock()
+				m.setEmbedded(block, false)
 
 				// If this was the focused block, clear focus
 				if m.FocusedBlock != nil && m.FocusedBlock.ID == block.ID {
@@ -631,106 +737,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m *Model) updateViewContent() {
-	var content strings.Builder
-
-	// Style definitions
-	blockStyle := lipgloss.NewStyle().
-		MarginBottom(1).
-		BorderStyle(lipgloss.ThickBorder()).
-		BorderForeground(lipgloss.Color(config.Get.Ui.BorderColor)).
-		BorderLeft(true)
-
-	focusedBlockStyle := blockStyle.
-		BorderStyle(lipgloss.ThickBorder()).
-		BorderRight(false).
-		BorderLeft(true).
-		BorderTop(false).
-		BorderBottom(false).
-		BorderForeground(lipgloss.Color(config.Get.Ui.BorderColorFocus))
-
-	headerStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(config.Get.Ui.HeaderColor))
-
-	runningStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(config.Get.Ui.RunningColor))
-
-	completedStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(config.Get.Ui.CompletedColor))
-
-	failedStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(config.Get.Ui.FailedColor))
-
+	items := make([]vlist.Item, 0, len(m.Commands)+1)
 	if neofetch.Print != "" {
-		content.WriteString(neofetch.Print + "\n")
+		items = append(items, textItem(neofetch.Print))
 	}
-
-	// Render each command block
 	for _, block := range m.Commands {
 		block.mu.Lock()
-
-		// Choose appropriate styles
-		style := blockStyle
-		if block.Focused {
-			style = focusedBlockStyle
-		}
-
-		headerCommandStyle := lipgloss.NewStyle()
-
-		// Create status indicator
-		var statusStr string
-		if block.IsRunning {
-			statusStr = zone.Mark(fmt.Sprintf("block_cancel_%d", block.ID), runningStyle.Render(""))
-			headerCommandStyle = headerCommandStyle.Foreground(lipgloss.Color(config.Get.Ui.HeaderCommandColorRunning))
-		} else {
-			if block.ExitCode != 0 {
-				headerCommandStyle = headerCommandStyle.Foreground(lipgloss.Color(config.Get.Ui.HeaderCommandColorFailed))
-				statusStr = failedStyle.Render(fmt.Sprintf("✗ %d", block.ExitCode))
-			} else {
-				headerCommandStyle = headerCommandStyle.Foreground(lipgloss.Color(config.Get.Ui.HeaderCommandColorDone))
-				duration := block.EndTime.Sub(block.StartTime)
-				if duration > 3*time.Second {
-					duration = duration.Round(time.Second)
-				} else {
-					duration = duration.Round(time.Millisecond)
-				}
-				statusStr = completedStyle.Render(fmt.Sprintf("✓ (%s)", duration))
-			}
-		}
-
-		if block.IsRunning {
-			headerCommandStyle = headerCommandStyle.Foreground(lipgloss.Color("7"))
-		}
-
-		copyButtonContent := " "
-		switch block.CopyStatus {
-		case CopyStatusSuccess:
-			copyButtonContent += "✓"
-		case CopyStatusFailure:
-			copyButtonContent += "✗ " + block.CopyError
-		}
-		copyButtonContent = lipgloss.NewStyle().Foreground(lipgloss.Color(config.Get.Ui.HeaderCopyColor)).Render(copyButtonContent)
-		copyBtn := zone.Mark(fmt.Sprintf("block_copy_%d", block.ID), copyButtonContent)
-
-		// Format header with command and status
-		header := headerStyle.Render(fmt.Sprintf("%s %s\n%s %s", block.Prompt, copyBtn, statusStr, headerCommandStyle.Render(block.Command)))
-
-		var blockContent string
-		if block.InDirectMode {
-			// Show a placeholder for blocks running in direct mode
-			blockContent = header + "\n\n[Running in full-screen mode - press any key to return when finished]"
-		} else {
-			// Normal rendering
-			output := ansicompiler.CompileAnsi(block.Output.String())
-			blockContent = header + "\n\n" + output
-		}
-		// Render the full block
-		content.WriteString(style.Render(blockContent) + "\n")
-
+		items = append(items, newBlockItem(block))
 		block.mu.Unlock()
 	}
-
-	// Update viewport content
-	m.Viewport.SetContent(content.String())
+	m.Viewport.SetItems(items)
 
 	// Auto-scroll to bottom for new content, unless user is manually scrolling
 	if !m.Scrolling {
@@ -743,6 +759,9 @@ func (m Model) View() string {
 	if m.Cmp.Active {
 		return m.CmpView()
 	}
+	if m.Jobs.Active {
+		return m.JobsView()
+	}
 	hasRunningBlocks := false
 	for _, block := range m.Commands {
 		if block.IsRunning {
@@ -754,11 +773,15 @@ func (m Model) View() string {
 	if !hasRunningBlocks {
 		osc = term.PromptEnd
 	}
+	input := m.Input.View()
+	if m.Nav.Active {
+		input = m.NavHelpView()
+	}
 	return zone.Scan(fmt.Sprintf(
 		"%s\n%s",
 		m.Viewport.View(),
 		lipgloss.NewStyle().
-			Render(m.Input.View()),
+			Render(input),
 	)) + osc
 }
 
@@ -769,10 +792,15 @@ func Run() error {
 		tea.WithMouseCellMotion(),
 	)
 
-	if _, err := exit.P.Run(); err != nil {
-		return err
+	final, err := exit.P.Run()
+	if m, ok := final.(Model); ok {
+		for _, block := range m.Commands {
+			block.spill.remove()
+			block.rec.remove()
+		}
 	}
-	return nil
+	RemoveFiles()
+	return err
 }
 
 var (
@@ -827,7 +855,8 @@ func enterCommand(m Model, cmd string) (Model, tea.Cmd) {
 	for k, v := range config.Get.Shell.Alias {
 		if words[0] == k {
 			words[0] = v
-			cmd = v + " " + strings.Join(words[1:], " ")
+			// keep the rest as it is, it may span multiple lines
+			cmd = v + cmd[len(k):]
 			break
 		}
 	}
@@ -836,24 +865,20 @@ func enterCommand(m Model, cmd string) (Model, tea.Cmd) {
 		block   *CommandBlock
 		execCmd tea.Cmd
 	)
-	if words[0] == "!" {
+	if b, args, ok := builtins.Lookup(cmd); ok {
+		block = m.ExecuteBuiltin(cmd, m.NextID, b, args)
+	} else if words[0] == "!" {
 		block, execCmd = ExecuteCommandFullScreen(cmd[2:], m.NextID)
 	}
-	for _, app := range fullScreenApps {
-		if words[0] == app {
-			block, execCmd = ExecuteCommandFullScreen(cmd, m.NextID)
-			break
-		}
-	}
 	if block == nil {
 		if words[0] == "clear" {
+			var terminate []tea.Cmd
 			for _, block := range m.Commands {
 				block.mu.Lock()
-				_ = commands.TerminateCommand(block.Cmd)
-				if block.OutputChan != nil {
-					close(block.OutputChan)
+				if block.IsRunning {
+					terminate = append(terminate, terminateBlock(block))
 				}
-				block.Output.Reset()
+				block.resetOutput()
 				_ = block.PTY.Close()
 				block.mu.Unlock()
 			}
@@ -861,76 +886,10 @@ func enterCommand(m Model, cmd string) (Model, tea.Cmd) {
 			exit.ClearTrackedCommands()
 			m.NextID = 1
 			m.updateViewContent()
-			return m, nil
-		}
-		if len(words) >= 2 {
-			fullScreen := false
-		complexFullscreenLoop:
-			for _, app := range fullScreenAppsComplex {
-				if app.Cmd == words[0] {
-					for _, subApp := range app.SubCmd {
-						if subApp.SubCmd == words[1] {
-							if len(subApp.NotArg) > 0 {
-								fullScreen = true
-								if len(words) >= 3 {
-									for _, arg := range subApp.NotArg {
-										for _, word := range words[2:] {
-											if arg == word {
-												fullScreen = false
-												break complexFullscreenLoop
-											}
-										}
-									}
-								} else {
-									break complexFullscreenLoop
-								}
-							}
-							if len(subApp.NotArgStartsWith) > 0 {
-								fullScreen = true
-								if len(words) >= 3 {
-									for _, arg := range subApp.NotArgStartsWith {
-										for _, word := range words[2:] {
-											if strings.HasPrefix(word, arg) {
-												fullScreen = false
-												break complexFullscreenLoop
-											}
-										}
-									}
-								} else {
-									break complexFullscreenLoop
-								}
-							}
-							if len(subApp.Arg) > 0 {
-								for _, arg := range subApp.Arg {
-									for _, word := range words {
-										if arg == word {
-											fullScreen = true
-											break complexFullscreenLoop
-										}
-									}
-								}
-							}
-							if !fullScreen && len(subApp.ArgStartsWith) > 0 {
-								for _, arg := range subApp.ArgStartsWith {
-									for _, word := range words {
-										if strings.HasPrefix(word, arg) {
-											fullScreen = true
-											break complexFullscreenLoop
-										}
-									}
-								}
-							}
-						}
-					}
-				}
-			}
-			if fullScreen {
-				block, execCmd = ExecuteCommandFullScreen(cmd, m.NextID)
-			}
-		}
-		if block == nil {
-			block, execCmd = ExecuteCommand(cmd, m.NextID)
+			return m, tea.Batch(terminate...)
 		}
+		// full-screen programs are found by their output, see altScreenPolicy
+		block, execCmd = ExecuteCommand(cmd, m.NextID)
 	}
 	m.Commands = append(m.Commands, block)
 	m.NextID++
@@ -940,54 +899,3 @@ func enterCommand(m Model, cmd string) (Model, tea.Cmd) {
 
 	return m, execCmd
 }
-
-type (
-	FullScreenCmd struct {
-		Cmd    string
-		SubCmd []FullScreenSubCmd
-	}
-
-	FullScreenSubCmd struct {
-		SubCmd           string
-		NotArg           []string
-		Arg              []string
-		ArgStartsWith    []string
-		NotArgStartsWith []string
-	}
-)
-
-var fullScreenAppsComplex = []FullScreenCmd{
-	{Cmd: "git", SubCmd: []FullScreenSubCmd{
-		{SubCmd: "commit",
-			NotArg:           []string{"-m", "-F", "-C", "--no-edit"},
-			NotArgStartsWith: []string{"--message=", "--file=", "--reuse-message=", "--fixup=", "--squash="},
-		},
-		{SubCmd: "rebase",
-			Arg:           []string{"-i"},
-			ArgStartsWith: []string{"--interactive="},
-		},
-		{SubCmd: "config",
-			Arg: []string{"--edit"},
-		},
-	}},
-}
-
-var fullScreenApps = []string{
-	"nvim",
-	//"docker",
-	"lazygit",
-	"vim",
-	"emacs",
-	"nano",
-	"zsh",
-	"bash",
-	"sh",
-	"dash",
-	"fish",
-	"elvish",
-	"tmux",
-	"htop",
-	"btop",
-	"pwsh",
-	"ssh",
-}
@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"testing"
+
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/tsukinoko-kun/ohmygosh/internal/config"
+)
+
+func TestDetectAltScreen(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{"plain output", "hello\n", false},
+		{"clear", "\x1b[H\x1b[2J\x1b[3J", false},
+		{"clear and home", "\x1b[2J\x1b[H", false},
+		{"alternate screen", "\x1b[?1049h\x1b[H", true},
+		{"old alternate screen", "\x1b[?47h", true},
+		{"leave alternate screen", "\x1b[?1049l", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectAltScreen(tt.output); got != tt.want {
+				t.Errorf("detectAltScreen(%q) = %v, want %v", tt.output, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnterBackground(t *testing.T) {
+	zone.NewGlobal()
+	config.Get.Shell.MaxHistoryLength = 0
+	tests := []struct {
+		name string
+		cmd  string
+	}{
+		{"current job", "bg"},
+		{"job by id", "bg 1"},
+		{"job by spec", "bg %1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := InitialModel()
+			job := &CommandBlock{ID: 1, Command: "sleep 10", IsRunning: true, Stopped: true, ExitCode: -1}
+			m.Commands = []*CommandBlock{job}
+			m.NextID = 2
+
+			m, _ = enterCommand(m, tt.cmd)
+			block := m.Commands[len(m.Commands)-1]
+			if block.ExitCode != 0 {
+				t.Fatalf("%q exited with %d: %q", tt.cmd, block.ExitCode, block.Output.String())
+			}
+			if job.Stopped {
+				t.Errorf("%q left the job stopped", tt.cmd)
+			}
+			if m.FocusedBlock != nil {
+				t.Errorf("%q focused block %d", tt.cmd, m.FocusedBlock.ID)
+			}
+		})
+	}
+}
+
+func TestEnterAltScreenCommand(t *testing.T) {
+	zone.NewGlobal()
+	saved := config.Get
+	t.Cleanup(func() { config.Get = saved })
+	config.Get.Shell.MaxHistoryLength = 0
+	// no session, the commands exit right away
+	config.Get.Shell.Exe = "true"
+	config.Get.Shell.Args = nil
+	config.Get.Ui.AltScreen = config.AltScreenRestart
+	config.Get.Ui.AltScreenCommands = map[string]string{
+		"vim": config.AltScreenEmbed,
+		"git": config.AltScreenPassthrough,
+	}
+
+	tests := []struct {
+		cmd    string
+		direct bool
+		policy string
+	}{
+		{"vim notes.txt", false, config.AltScreenEmbed},
+		{"git rebase -i HEAD~2", false, config.AltScreenPassthrough},
+		{"git commit", false, config.AltScreenPassthrough},
+		{"htop", false, config.AltScreenRestart},
+		{"! vim notes.txt", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cmd, func(t *testing.T) {
+			m := InitialModel()
+			m, _ = enterCommand(m, tt.cmd)
+			block := m.Commands[len(m.Commands)-1]
+			if block.PTY != nil {
+				t.Cleanup(func() { _ = block.PTY.Close() })
+			}
+			if block.InDirectMode != tt.direct {
+				t.Fatalf("%q in direct mode: %v, want %v", tt.cmd, block.InDirectMode, tt.direct)
+			}
+			if policy := altScreenPolicy(block.Command); !tt.direct && policy != tt.policy {
+				t.Errorf("altScreenPolicy(%q) = %q, want %q", block.Command, policy, tt.policy)
+			}
+		})
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"syscall"
 
 	zone "github.com/lrstanley/bubblezone"
+	"github.com/tsukinoko-kun/ohmygosh/internal/cast"
 	"github.com/tsukinoko-kun/ohmygosh/internal/commands"
+	"github.com/tsukinoko-kun/ohmygosh/internal/ipc"
 	"github.com/tsukinoko-kun/ohmygosh/internal/metadata"
 	"github.com/tsukinoko-kun/ohmygosh/internal/shell"
 	"github.com/tsukinoko-kun/ohmygosh/internal/term"
@@ -21,11 +23,19 @@ func main() {
 		fmt.Println(metadata.Version)
 		return
 	}
+	if len(os.Args) > 1 && os.Args[1] == "ipc" {
+		os.Exit(ipc.Main(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "play" {
+		os.Exit(cast.Main(os.Args[2:]))
+	}
 
 	go term.InheritSize()
 	go processSignals()
 	go shell.Init()
+	go ui.RemoveStaleFiles()
 	defer shell.ClearIPC()
+	defer shell.CloseSession()
 
 	zone.NewGlobal()
 	defer zone.Close()
@@ -39,7 +49,7 @@ func main() {
 	wg.Add(len(exit.TrackedCommands))
 	for _, cmd := range exit.TrackedCommands {
 		go func() {
-			_ = commands.TerminateCommand(cmd.Cmd)
+			_, _ = commands.TerminateCommand(cmd.Cmd)
 			wg.Done()
 		}()
 	}
@@ -48,6 +58,7 @@ func main() {
 	if exit.ExitCode != 0 {
 		zone.Close()
 		shell.ClearIPC()
+		shell.CloseSession()
 		os.Exit(exit.ExitCode)
 	}
 }
@@ -58,17 +69,19 @@ func processSignals() {
 	<-c
 	exit.ExitCode = 130
 	shell.ClearIPC()
+	ui.RemoveFiles()
 
 	wg := sync.WaitGroup{}
 	wg.Add(len(exit.TrackedCommands))
 	for _, cmd := range exit.TrackedCommands {
 		go func() {
-			_ = commands.TerminateCommand(cmd.Cmd)
+			_, _ = commands.TerminateCommand(cmd.Cmd)
 			wg.Done()
 		}()
 	}
 	wg.Wait()
 
+	shell.CloseSession()
 	zone.Close()
 	os.Exit(exit.ExitCode)
 }